@@ -3,7 +3,7 @@
 Expand the name of the chart.
 */}}
 {{- define "name" -}}
-{{- default .Chart.Name .Values.nameOverride | trunc 24 | trimSuffix "-" -}}
+{{- regexReplaceAll "-+$" (default .Chart.Name .Values.nameOverride | trunc 24) "" -}}
 {{- end -}}
 
 {{/*
@@ -12,12 +12,12 @@ We truncate at 63 chars because some Kubernetes name fields are limited to this
 */}}
 {{- define "fullname" -}}
 {{- $name := default .Chart.Name .Values.nameOverride -}}
-{{- printf "%s-%s" .Release.Name $name | trimSuffix "-app" | trunc 63 | trimSuffix "-" -}}
+{{- regexReplaceAll "-+$" (printf "%s-%s" .Release.Name $name | trimSuffix "-app" | trunc 63) "" -}}
 {{- end -}}
 
 {{- define "appname" -}}
 {{- $releaseName := default .Release.Name .Values.releaseOverride -}}
-{{- printf "%s" $releaseName | trunc 63 | trimSuffix "-" -}}
+{{- regexReplaceAll "-+$" (printf "%s" $releaseName | trunc 63) "" -}}
 {{- end -}}
 
 {{- define "imagename" -}}
@@ -30,13 +30,107 @@ We truncate at 63 chars because some Kubernetes name fields are limited to this
 
 {{- define "trackableappname" -}}
 {{- $trackableName := printf "%s-%s" (include "appname" .) .Values.application.track -}}
-{{- $trackableName | trimSuffix "-stable" | trunc 63 | trimSuffix "-" -}}
+{{- regexReplaceAll "-+$" ($trackableName | trimSuffix "-stable" | trunc 63) "" -}}
+{{- end -}}
+
+{{/*
+Create a name for an object that belongs to the application, like a worker or a cronjob, from trackableappname and a suffix.
+Names longer than the limit (63 characters by default) are shortened and get a hash of the full name appended,
+so that different suffixes still give different names.
+*/}}
+{{- define "suffixedname" -}}
+{{- $limit := .limit | default 63 | int -}}
+{{- $name := regexReplaceAll "-+$" (printf "%s-%s" (include "trackableappname" .context) .suffix) "" -}}
+{{- if gt (len $name) $limit -}}
+{{- $hash := sha256sum $name | trunc 8 -}}
+{{- $name = printf "%s-%s" (regexReplaceAll "-+$" ($name | trunc (sub $limit 9 | int)) "") $hash -}}
+{{- end -}}
+{{- $name -}}
+{{- end -}}
+
+{{/*
+The init container that waits for the dependencies of the application, if waitForDependencies.enabled is set.
+*/}}
+{{- define "waitfordependencies" -}}
+{{- if .Values.waitForDependencies.enabled -}}
+{{ include "waitfordependenciescontainer" . }}
+{{- end -}}
+{{- end -}}
+
+{{/*
+The init container that waits for the dependencies of the application with the wait-for tool. It gets only the
+environment it needs to find the database: application.secretName, the POSTGRES_ variables of a managed database
+and DATABASE_URL. extraEnv and extraEnvFrom are not passed to it.
+*/}}
+{{- define "waitfordependenciescontainer" -}}
+{{- with .Values.waitForDependencies -}}
+- name: wait-for-dependencies
+  image: "{{ .image.repository }}:{{ .image.tag }}"
+  imagePullPolicy: {{ .image.pullPolicy | default (eq (toString .image.tag) "latest" | ternary "Always" "IfNotPresent") }}
+  args:
+  - "-timeout={{ .timeout }}"
+  {{- if .postgres }}
+  - "-postgres"
+  {{- end }}
+  {{- range .dependencies }}
+  - {{ . | quote }}
+  {{- end }}
+  {{- if $.Values.application.secretName }}
+  envFrom:
+  - secretRef:
+      name: {{ $.Values.application.secretName }}
+  {{- end }}
+  {{- if or $.Values.postgresql.managed $.Values.application.database_url }}
+  env:
+  {{- if $.Values.postgresql.managed }}
+  - name: POSTGRES_USER
+    valueFrom:
+      secretKeyRef:
+        name: app-postgres
+        key: username
+  - name: POSTGRES_PASSWORD
+    valueFrom:
+      secretKeyRef:
+        name: app-postgres
+        key: password
+  - name: POSTGRES_HOST
+    valueFrom:
+      secretKeyRef:
+        name: app-postgres
+        key: privateIP
+  {{- end }}
+  {{- if $.Values.application.database_url }}
+  - name: DATABASE_URL
+    value: {{ $.Values.application.database_url | quote }}
+  {{- end }}
+  {{- end }}
+  {{- with .resources }}
+  resources:
+    {{- toYaml . | nindent 4 }}
+  {{- end }}
+  {{- with $.Values.containerSecurityContext }}
+  securityContext:
+    {{- toYaml . | nindent 4 }}
+  {{- end }}
+{{- end -}}
 {{- end -}}
 
 {{/*
 Templates for worker
 */}}
 
+{{/*
+The app.kubernetes.io/component label of a worker: its name as a valid label value, hashed like suffixedname if it
+is too long, so that every worker keeps a label of its own.
+*/}}
+{{- define "workercomponent" -}}
+{{- $name := regexReplaceAll "-+$" . "" -}}
+{{- if gt (len $name) 63 -}}
+{{- $name = printf "%s-%s" (regexReplaceAll "-+$" ($name | trunc 54) "") (sha256sum $name | trunc 8) -}}
+{{- end -}}
+{{- $name -}}
+{{- end -}}
+
 {{- define "workerimagename" -}}
 {{- if hasKey .worker "image" -}}
 {{-   if and (hasKey .worker.image "repository") (hasKey .worker.image "tag") -}}
@@ -71,6 +165,22 @@ Templates for cronjob
 {{- end -}}
 {{- end -}}
 
+{{/*
+Get the apiVersion for a kind from a list of candidate versions, newest first.
+A cluster reports its resources as "group/version/Kind", so the newest version the cluster serves is used.
+Without resource information, e.g. with `helm template`, the newest version is used.
+*/}}
+{{- define "apiversion" -}}
+{{- $capabilities := .context.Capabilities -}}
+{{- $selected := first .versions -}}
+{{- range $version := reverse .versions -}}
+{{-   if $capabilities.APIVersions.Has (printf "%s/%s" $version $.kind) -}}
+{{-     $selected = $version -}}
+{{-   end -}}
+{{- end -}}
+{{- $selected -}}
+{{- end -}}
+
 {{/*
 Get a hostname from URL
 */}}
@@ -91,7 +201,7 @@ Get SecRule's arguments with unescaped single&double quotes
 Generate a name for a Persistent Volume Claim
 */}}
 {{- define "pvcName" -}}
-{{- printf "%s-%s" (include "fullname" .context) .name | trunc 63 | trimSuffix "-" -}}
+{{- regexReplaceAll "-+$" (printf "%s-%s" (include "fullname" .context) .name | trunc 63) "" -}}
 {{- end -}}
 
 {{- define "sharedlabels" -}}
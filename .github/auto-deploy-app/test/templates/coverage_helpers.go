@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+// coverageFunc is the template function the instrumentation calls at the start of every branch.
+const coverageFunc = "recordTemplateBranch"
+
+// chartCoverage collects the branches taken by the renders of the working tree chart with -template-coverage.
+var chartCoverage = newTemplateCoverage()
+
+// templateCoverage records which branches of the conditionals in the templates of a chart were taken. Both
+// branches of every `if`, `else if` and `with` count, also when there is no `else`: a template is only
+// covered when the tests render it with the condition true and with the condition false.
+type templateCoverage struct {
+	branches map[string]*templateBranch
+	markers  map[string]parse.Node // the actions recording the branches, by branch
+}
+
+// templateBranch is one branch of a conditional, see templateCoverage.
+type templateBranch struct {
+	template string // path of the template in the chart, like templates/deployment.yaml
+	position string // line:column of the condition
+	action   string // if, else if or with
+	branch   string // then or else
+	taken    bool
+}
+
+func newTemplateCoverage() *templateCoverage {
+	return &templateCoverage{branches: make(map[string]*templateBranch), markers: make(map[string]parse.Node)}
+}
+
+// render renders the templates of a chart like engine.Render does, with every branch of the conditionals
+// instrumented to record that it was taken. helm's engine has no way to add template functions, so the
+// instrumented templates are executed with a copy of its function map that is limited to the functions
+// the chart uses; a template using another function fails to parse here.
+func (c *templateCoverage) render(ch *chart.Chart, vals chartutil.Values) (rendered map[string]string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("rendering template failed: %v", r)
+		}
+	}()
+
+	t := template.New("gotpl").Option("missingkey=zero")
+	t.Funcs(coverageFuncMap(t, c))
+
+	basePath := path.Join(ch.ChartFullPath(), "templates")
+	next := map[string]interface{}{
+		"Chart": struct {
+			chart.Metadata
+			IsRoot bool
+		}{*ch.Metadata, ch.IsRoot()},
+		"Release":      vals["Release"],
+		"Capabilities": vals["Capabilities"],
+		"Values":       vals["Values"],
+		"Subcharts":    map[string]interface{}{},
+	}
+
+	names := make([]string, 0, len(ch.Templates))
+	for _, file := range ch.Templates {
+		name := path.Join(ch.ChartFullPath(), file.Name)
+		if _, err := t.New(name).Parse(string(file.Data)); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			c.instrument(tmpl.Tree, ch.ChartFullPath()+"/")
+		}
+	}
+
+	// in the order of the engine, so that the same branches are taken before a template fails
+	sort.Slice(names, func(i, j int) bool {
+		di, dj := strings.Count(names[i], "/"), strings.Count(names[j], "/")
+		if di == dj {
+			return names[i] > names[j]
+		}
+		return di > dj
+	})
+
+	rendered = make(map[string]string, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(path.Base(name), "_") {
+			continue
+		}
+		next["Template"] = chartutil.Values{"Name": name, "BasePath": basePath}
+		var buf strings.Builder
+		if err := t.ExecuteTemplate(&buf, name, next); err != nil {
+			return nil, err
+		}
+		rendered[name] = strings.ReplaceAll(buf.String(), "<no value>", "")
+	}
+	return rendered, nil
+}
+
+// coverageFuncMap returns the functions of helm's engine that the chart uses, with include and tpl bound
+// to t, and the function the instrumentation calls.
+func coverageFuncMap(t *template.Template, c *templateCoverage) template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+
+	funcs["toYaml"] = func(v interface{}) string {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(string(data), "\n")
+	}
+	funcs["fromYaml"] = func(str string) map[string]interface{} {
+		m := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(str), &m); err != nil {
+			m["Error"] = err.Error()
+		}
+		return m
+	}
+	funcs["toJson"] = func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+	funcs["fromJson"] = func(str string) map[string]interface{} {
+		m := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(str), &m); err != nil {
+			m["Error"] = err.Error()
+		}
+		return m
+	}
+	funcs["include"] = func(name string, data interface{}) (string, error) {
+		var buf strings.Builder
+		err := t.ExecuteTemplate(&buf, name, data)
+		return buf.String(), err
+	}
+	funcs["tpl"] = func(text string, vals chartutil.Values) (string, error) {
+		name, err := vals.PathValue("Template.Name")
+		if err != nil {
+			return "", err
+		}
+		clone, err := t.Clone()
+		if err != nil {
+			return "", err
+		}
+		tmpl, err := clone.New(name.(string)).Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, vals); err != nil {
+			return "", err
+		}
+		return strings.ReplaceAll(buf.String(), "<no value>", ""), nil
+	}
+	funcs["required"] = func(warn string, val interface{}) (interface{}, error) {
+		if val == nil || val == "" {
+			return val, errors.New(warn)
+		}
+		return val, nil
+	}
+	funcs["fail"] = func(msg string) (string, error) {
+		return "", errors.New(msg)
+	}
+	funcs["lookup"] = func(string, string, string, string) (map[string]interface{}, error) {
+		return map[string]interface{}{}, nil
+	}
+
+	funcs[coverageFunc] = func(id string) string {
+		c.branches[id].taken = true
+		return ""
+	}
+	return funcs
+}
+
+// instrument adds a call of coverageFunc to the start of both branches of every conditional in the tree,
+// adding an empty else branch where there is none. The calls output nothing, so the output doesn't change.
+func (c *templateCoverage) instrument(tree *parse.Tree, prefix string) {
+	c.instrumentList(tree, prefix, tree.Root)
+}
+
+func (c *templateCoverage) instrumentList(tree *parse.Tree, prefix string, list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			c.instrumentBranches(tree, prefix, "if", &n.BranchNode)
+		case *parse.WithNode:
+			c.instrumentBranches(tree, prefix, "with", &n.BranchNode)
+		case *parse.RangeNode:
+			c.instrumentList(tree, prefix, n.List)
+			c.instrumentList(tree, prefix, n.ElseList)
+		}
+	}
+}
+
+func (c *templateCoverage) instrumentBranches(tree *parse.Tree, prefix string, action string, node *parse.BranchNode) {
+	// before the branches are instrumented, the context of the location prints them
+	location, _ := tree.ErrorContext(node)
+	position := strings.TrimPrefix(location, tree.ParseName+":")
+	template := strings.TrimPrefix(tree.ParseName, prefix)
+
+	c.instrumentList(tree, prefix, node.List)
+	node.List.Nodes = append([]parse.Node{c.marker(template, position, action, "then")}, node.List.Nodes...)
+
+	// {{ else if }} is parsed as an else branch that only holds the next if
+	if node.ElseList != nil && len(node.ElseList.Nodes) == 1 {
+		if elseIf, ok := node.ElseList.Nodes[0].(*parse.IfNode); ok {
+			c.instrumentBranches(tree, prefix, "else if", &elseIf.BranchNode)
+			return
+		}
+	}
+	c.instrumentList(tree, prefix, node.ElseList)
+	if node.ElseList == nil {
+		node.ElseList = &parse.ListNode{NodeType: parse.NodeList, Pos: node.Pos}
+	}
+	node.ElseList.Nodes = append([]parse.Node{c.marker(template, position, action, "else")}, node.ElseList.Nodes...)
+}
+
+// marker registers a branch and returns the action that records it as taken.
+func (c *templateCoverage) marker(template string, position string, action string, branch string) parse.Node {
+	id := template + ":" + position + ":" + branch
+	if _, ok := c.branches[id]; !ok {
+		c.branches[id] = &templateBranch{template: template, position: position, action: action, branch: branch}
+	}
+
+	// the nodes are parsed instead of built, they need a tree to be printed in errors
+	if _, ok := c.markers[id]; !ok {
+		tree := parse.New(id)
+		tree.Mode = parse.SkipFuncCheck
+		if _, err := tree.Parse("{{"+coverageFunc+" "+strconv.Quote(id)+"}}", "", "", map[string]*parse.Tree{}); err != nil {
+			panic(err)
+		}
+		c.markers[id] = tree.Root.Nodes[0]
+	}
+	return c.markers[id]
+}
+
+// report prints the share of branches taken per template, followed by the branches that were never
+// taken. It returns false if a template has less than min percent of its branches taken.
+func (c *templateCoverage) report(w io.Writer, min float64) bool {
+	type templateStats struct {
+		taken, total int
+	}
+	stats := make(map[string]*templateStats)
+	var notTaken []*templateBranch
+	for _, b := range c.branches {
+		s, ok := stats[b.template]
+		if !ok {
+			s = &templateStats{}
+			stats[b.template] = s
+		}
+		s.total++
+		if b.taken {
+			s.taken++
+		} else {
+			notTaken = append(notTaken, b)
+		}
+	}
+
+	templates := make([]string, 0, len(stats))
+	width := 0
+	for name := range stats {
+		templates = append(templates, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(templates)
+	sort.Slice(notTaken, func(i, j int) bool {
+		a, b := notTaken[i], notTaken[j]
+		if a.template != b.template {
+			return a.template < b.template
+		}
+		if a.position != b.position {
+			return comparePositions(a.position, b.position) < 0
+		}
+		return a.branch > b.branch
+	})
+
+	ok := true
+	var taken, total int
+	fmt.Fprintln(w, "template branch coverage:")
+	for _, name := range templates {
+		s := stats[name]
+		taken, total = taken+s.taken, total+s.total
+		percent := 100 * float64(s.taken) / float64(s.total)
+		fmt.Fprintf(w, "  %-*s %4d/%-4d %5.1f%%\n", width, name, s.taken, s.total, percent)
+		if percent < min {
+			ok = false
+		}
+	}
+	if total > 0 {
+		fmt.Fprintf(w, "  %-*s %4d/%-4d %5.1f%%\n", width, "total", taken, total, 100*float64(taken)/float64(total))
+	}
+
+	if len(notTaken) > 0 {
+		fmt.Fprintln(w, "branches never taken:")
+		for _, b := range notTaken {
+			fmt.Fprintf(w, "  %s:%s: %s branch of %s\n", b.template, b.position, b.branch, b.action)
+		}
+	}
+	if !ok {
+		fmt.Fprintf(w, "template branch coverage is below %.1f%% for some templates\n", min)
+	}
+	return ok
+}
+
+// comparePositions compares line:column positions numerically.
+func comparePositions(a string, b string) int {
+	al, ac, _ := strings.Cut(a, ":")
+	bl, bc, _ := strings.Cut(b, ":")
+	for _, pair := range [][2]string{{al, bl}, {ac, bc}} {
+		x, _ := strconv.Atoi(pair[0])
+		y, _ := strconv.Atoi(pair[1])
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// record renders the chart instrumented and checks that the output is the same as rendered by helm's
+// engine. The branches are also recorded when helm failed to render the chart, the tests for invalid
+// values take the branches that fail.
+func (c *templateCoverage) record(ch *chart.Chart, vals chartutil.Values, rendered map[string]string, renderErr error) error {
+	instrumented, err := c.render(ch, vals)
+	if renderErr != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to render the instrumented templates: %w", err)
+	}
+	for name, output := range rendered {
+		if instrumented[name] != output {
+			return fmt.Errorf("the instrumented template %s renders differently than with helm", name)
+		}
+	}
+	return nil
+}
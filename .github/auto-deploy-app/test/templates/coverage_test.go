@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if *templateCoverageFlag && !chartCoverage.report(os.Stdout, *templateCoverageMin) && code == 0 {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func TestTemplateCoverage(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "coverage", Version: "0.1.0"},
+		Templates: []*chart.File{
+			{Name: "templates/_helpers.tpl", Data: []byte(`{{- define "replicas" -}}
+{{- with .Values.replicas -}}
+{{ . }}
+{{- else -}}
+1
+{{- end -}}
+{{- end -}}
+`)},
+			{Name: "templates/configmap.yaml", Data: []byte(`{{- if .Values.enabled }}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+data:
+  replicas: {{ include "replicas" . | quote }}
+  {{- if eq .Values.mode "a" }}
+  mode: a
+  {{- else if eq .Values.mode "b" }}
+  mode: b
+  {{- else }}
+  mode: other
+  {{- end }}
+  {{- range .Values.extra }}
+  {{- if . }}
+  extra: {{ tpl . $ }}
+  {{- end }}
+  {{- end }}
+{{- end }}
+`)},
+		},
+	}
+
+	coverage := newTemplateCoverage()
+	for _, values := range []map[string]interface{}{
+		{"enabled": false},
+		{"enabled": true, "mode": "a", "replicas": 3, "extra": []interface{}{"{{ .Release.Name }}"}},
+		{"enabled": true, "mode": "c"},
+	} {
+		vals, err := chartutil.ToRenderValues(ch, values, chartutil.ReleaseOptions{Name: "release"}, chartutil.DefaultCapabilities)
+		require.NoError(t, err)
+		rendered, err := engine.Render(ch, vals)
+		require.NoError(t, err)
+		require.NoError(t, coverage.record(ch, vals, rendered, nil))
+	}
+
+	var report strings.Builder
+	require.True(t, coverage.report(&report, 70))
+	require.Equal(t, `template branch coverage:
+  templates/_helpers.tpl      2/2    100.0%
+  templates/configmap.yaml    5/7     71.4%
+  total                       7/9     77.8%
+branches never taken:
+  templates/configmap.yaml:10:14: then branch of else if
+  templates/configmap.yaml:16:9: else branch of if
+`, report.String())
+
+	report.Reset()
+	require.False(t, coverage.report(&report, 75))
+	require.Contains(t, report.String(), "template branch coverage is below 75.0% for some templates")
+}
+
+func TestTemplateCoverage_RenderDiffers(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: "v2", Name: "coverage", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte("name: {{ .Release.Name }}\n")}},
+	}
+	vals, err := chartutil.ToRenderValues(ch, nil, chartutil.ReleaseOptions{Name: "release"}, chartutil.DefaultCapabilities)
+	require.NoError(t, err)
+
+	rendered := map[string]string{"coverage/templates/configmap.yaml": "name: other\n"}
+	require.EqualError(t, newTemplateCoverage().record(ch, vals, rendered, nil), "the instrumented template coverage/templates/configmap.yaml renders differently than with helm")
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/require"
-	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	batchV1 "k8s.io/api/batch/v1"
 	coreV1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -86,10 +86,9 @@ func TestCronjobMeta(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, map[string]string{
 					"app.gitlab.com/app": "auto-devops-examples/minimal-ruby-app",
 					"app.gitlab.com/env": "prod",
@@ -163,10 +162,9 @@ func TestCronjobSchedule(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedSchedule, cronjob.Spec.Schedule)
 			}
 		})
@@ -221,10 +219,9 @@ func TestCronjobImage(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedImage, cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image)
 			}
 		})
@@ -437,10 +434,9 @@ func TestCronjobLivenessAndReadiness(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedLivenessProbe, cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].LivenessProbe)
 				require.Equal(t, tc.ExpectedReadinessProbe, cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].ReadinessProbe)
 			}
@@ -498,10 +494,9 @@ func TestCronjobNodeSelector(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedNodeSelector, cronjob.Spec.JobTemplate.Spec.Template.Spec.NodeSelector)
 			}
 		})
@@ -582,10 +577,9 @@ func TestCronjobTolerations(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedTolerations, cronjob.Spec.JobTemplate.Spec.Template.Spec.Tolerations)
 			}
 		})
@@ -655,10 +649,9 @@ func TestCronjobResources(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedResources, cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Resources )
 			}
 		})
@@ -743,10 +736,9 @@ func TestCronjobTemplateWithVolumeMounts(t *testing.T) {
 			}
 			output := mustRenderTemplate(t, options, releaseName, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				for i, expectedVolume := range tc.expectedVolumes {
 					require.Equal(t, expectedVolume.Name, cronjob.Spec.JobTemplate.Spec.Template.Spec.Volumes[i].Name)
 					if cronjob.Spec.JobTemplate.Spec.Template.Spec.Volumes[i].ConfigMap != nil {
@@ -852,10 +844,9 @@ func TestCronjobAffinity(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedAffinity, cronjob.Spec.JobTemplate.Spec.Template.Spec.Affinity)
 			}
 		})
@@ -922,9 +913,8 @@ func TestCronJobTemplateWithExtraEnvFrom(t *testing.T) {
 			}
 			output := mustRenderTemplate(t, options, releaseName, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
-			for _, cronjob := range cronjobs.Items {
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
+			for _, cronjob := range cronjobs {
 				require.Contains(t, cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].EnvFrom, tc.expectedEnvFrom)
 			}
 		})
@@ -956,9 +946,8 @@ func TestCronJobTemplateWithSecurityContext(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
-			for _, cronjob := range cronjobs.Items {
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
+			for _, cronjob := range cronjobs {
 				require.Equal(t, *cronjob.Spec.JobTemplate.Spec.Template.Spec.SecurityContext.WindowsOptions.GMSACredentialSpecName, tc.expectedSecurityContextName)
 			}
 		})
@@ -992,9 +981,8 @@ func TestCronJobTemplateWithContainerSecurityContext(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
-			for _, cronjob := range cronjobs.Items {
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
+			for _, cronjob := range cronjobs {
 				require.Equal(t, cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities.Drop, tc.expectedSecurityContextCapabilities)
 			}
 		})
@@ -1094,10 +1082,9 @@ func TestCronjobImagePullSecrets(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedImagePullSecrets, cronjob.Spec.JobTemplate.Spec.Template.Spec.ImagePullSecrets)
 			}
 		})
@@ -1184,10 +1171,9 @@ func TestCronjobPodAnnotations(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/cronjob.yaml"}, nil)
 
-			var cronjobs batchV1beta1.CronJobList
-			helm.UnmarshalK8SYaml(t, output, &cronjobs)
+			cronjobs := mustGetAll[*batchV1.CronJob](t, mustDecodeObjects(t, output))
 
-			for _, cronjob := range cronjobs.Items {
+			for _, cronjob := range cronjobs {
 				require.Equal(t, tc.ExpectedPodAnnotations, cronjob.Spec.JobTemplate.Spec.Template.ObjectMeta.Annotations)
 			}
 		})
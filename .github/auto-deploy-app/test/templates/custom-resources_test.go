@@ -8,7 +8,6 @@ import (
 	"github.com/gruntwork-io/terratest/modules/helm"
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/gruntwork-io/terratest/modules/random"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestCustomResource(t *testing.T) {
@@ -16,8 +15,9 @@ func TestCustomResource(t *testing.T) {
 	Template := "templates/custom-resources.yaml" // Your template file path
 
 	tcs := []struct {
-		CaseName string
-		Values   map[string]string
+		CaseName     string
+		Values       map[string]string
+		ExpectedKeys []string
 	}{
 		{
 			CaseName: "test-single-custom-resource",
@@ -26,6 +26,7 @@ func TestCustomResource(t *testing.T) {
 				"customResources[0].kind":          "IngressRoute",
 				"customResources[0].metadata.name": "ingress-route",
 			},
+			ExpectedKeys: []string{"IngressRoute/ingress-route"},
 		},
 		{
 			CaseName: "test-multiple-custom-resources",
@@ -37,6 +38,7 @@ func TestCustomResource(t *testing.T) {
 				"customResources[1].kind":          "Pod",
 				"customResources[1].metadata.name": "my-pod",
 			},
+			ExpectedKeys: []string{"Pod/my-pod", "IngressRoute/ingress-route"},
 		},
 	}
 
@@ -52,8 +54,7 @@ func TestCustomResource(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, []string{Template}, nil)
 
-			var renderedObjects *unstructured.Unstructured
-			helm.UnmarshalK8SYaml(t, output, &renderedObjects)
+			require.Equal(t, tc.ExpectedKeys, mustDecodeObjects(t, output).keys())
 		})
 	}
 }
@@ -90,11 +91,9 @@ func TestCustomResourceWithTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, []string{Template}, nil)
 
-			var renderedObjects *unstructured.Unstructured
-			helm.UnmarshalK8SYaml(t, output, &renderedObjects)
-
 			// Check the name of the rendered object
-			require.Equal(t, tc.expectedName, renderedObjects.GetName(), "The name of the custom resource should be %s as it is templated", tc.expectedName)
+			renderedObject := mustGetCustomResource(t, mustDecodeObjects(t, output), "IngressRoute", tc.expectedName)
+			require.Equal(t, tc.expectedName, renderedObject.GetName(), "The name of the custom resource should be %s as it is templated", tc.expectedName)
 		})
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/require"
 	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
 	coreV1 "k8s.io/api/core/v1"
 )
 
@@ -28,14 +29,14 @@ func TestInitializeDatabaseUrlEnvironmentVariable(t *testing.T) {
 				"application.initializeCommand": "echo initialize",
 			},
 			ExpectedDatabaseUrl: "PRESENT",
-			Template:            "templates/db-initialize-job.yaml",
+			Template:            "templates/hooks.yaml",
 		},
 		{
 			CaseName: "missing-db-initialize",
 			Values: map[string]string{
 				"application.initializeCommand": "echo initialize",
 			},
-			Template: "templates/db-initialize-job.yaml",
+			Template: "templates/hooks.yaml",
 		},
 	}
 
@@ -91,7 +92,7 @@ func TestInitializeDatabaseImagePullSecrets(t *testing.T) {
 					Name: "gitlab-registry",
 				},
 			},
-			Template: "templates/db-initialize-job.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "present-secret",
@@ -104,7 +105,7 @@ func TestInitializeDatabaseImagePullSecrets(t *testing.T) {
 					Name: "expected-secret",
 				},
 			},
-			Template: "templates/db-initialize-job.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "multiple-secrets",
@@ -121,7 +122,7 @@ func TestInitializeDatabaseImagePullSecrets(t *testing.T) {
 					Name: "additional-secret",
 				},
 			},
-			Template: "templates/db-initialize-job.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "missing-secret",
@@ -130,7 +131,7 @@ func TestInitializeDatabaseImagePullSecrets(t *testing.T) {
 				"image.secrets":                 "null",
 			},
 			ExpectedImagePullSecrets: nil,
-			Template:                 "templates/db-initialize-job.yaml",
+			Template:                 "templates/hooks.yaml",
 		},
 	}
 
@@ -178,7 +179,7 @@ func TestInitializeDatabaseLabels(t *testing.T) {
 				"application.initializeCommand": "echo initialize",
 			},
 			ExpectedLabels: nil,
-			Template:       "templates/db-initialize-job.yaml",
+			Template:       "templates/hooks.yaml",
 		},
 		{
 			CaseName: "one label",
@@ -190,7 +191,7 @@ func TestInitializeDatabaseLabels(t *testing.T) {
 			ExpectedLabels: map[string]string{
 				"firstLabel": "expected-label",
 			},
-			Template: "templates/db-initialize-job.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "multiple labels",
@@ -204,7 +205,7 @@ func TestInitializeDatabaseLabels(t *testing.T) {
 				"firstLabel":  "expected-label",
 				"secondLabel": "expected-label",
 			},
-			Template: "templates/db-initialize-job.yaml",
+			Template: "templates/hooks.yaml",
 		},
 	} {
 		t.Run(tc.CaseName, func(t *testing.T) {
@@ -234,7 +235,7 @@ func TestInitializeDatabaseLabels(t *testing.T) {
 
 func TestInitializeDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 	releaseName := "initialize-application-database-extra-envfrom"
-	templates := []string{"templates/db-initialize-job.yaml"}
+	templates := []string{"templates/hooks.yaml"}
 
 	tcs := []struct {
 		name            string
@@ -245,6 +246,7 @@ func TestInitializeDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 			name: "with extra envfrom secret test",
 			values: map[string]string{
 				"application.initializeCommand":  "echo initialize",
+				"application.secretName":         "app-secret",
 				"extraEnvFrom[0].secretRef.name": "secret-name-test",
 			},
 			expectedEnvFrom: coreV1.EnvFromSource{
@@ -259,6 +261,7 @@ func TestInitializeDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 			name: "test with extra env from secret using templating values",
 			values: map[string]string{
 				"application.initializeCommand":  "echo initialize",
+				"application.secretName":         "app-secret",
 				"extraEnvFrom[0].secretRef.name": "secret-name-{{ .Release.Name }}",
 			},
 			expectedEnvFrom: coreV1.EnvFromSource{
@@ -282,18 +285,15 @@ func TestInitializeDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
-				require.Contains(t, deployment.Spec.Template.Spec.Containers[0].EnvFrom, tc.expectedEnvFrom)
-			}
+			job := mustGet[*batchV1.Job](t, mustDecodeObjects(t, output), releaseName+"-db-initialize")
+			require.Contains(t, job.Spec.Template.Spec.Containers[0].EnvFrom, tc.expectedEnvFrom)
 		})
 	}
 }
 
 func TestInitializeDatabaseTemplateWithExtraEnv(t *testing.T) {
 	releaseName := "initialize-application-database-extra-env"
-	templates := []string{"templates/db-initialize-job.yaml"}
+	templates := []string{"templates/hooks.yaml"}
 
 	tcs := []struct {
 		name        string
@@ -325,11 +325,8 @@ func TestInitializeDatabaseTemplateWithExtraEnv(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
-				require.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, tc.expectedEnv)
-			}
+			job := mustGet[*batchV1.Job](t, mustDecodeObjects(t, output), releaseName+"-db-initialize")
+			require.Contains(t, job.Spec.Template.Spec.Containers[0].Env, tc.expectedEnv)
 		})
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/require"
 	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
 	coreV1 "k8s.io/api/core/v1"
 )
 
@@ -28,14 +30,14 @@ func TestMigrateDatabaseUrlEnvironmentVariable(t *testing.T) {
 				"application.migrateCommand": "echo migrate",
 			},
 			ExpectedDatabaseUrl: "PRESENT",
-			Template:            "templates/db-migrate-hook.yaml",
+			Template:            "templates/hooks.yaml",
 		},
 		{
 			CaseName: "missing-db-migrate",
 			Values: map[string]string{
 				"application.migrateCommand": "echo migrate",
 			},
-			Template: "templates/db-migrate-hook.yaml",
+			Template: "templates/hooks.yaml",
 		},
 	}
 
@@ -91,7 +93,7 @@ func TestMigrateDatabaseImagePullSecrets(t *testing.T) {
 					Name: "gitlab-registry",
 				},
 			},
-			Template: "templates/db-migrate-hook.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "present-secret",
@@ -104,7 +106,7 @@ func TestMigrateDatabaseImagePullSecrets(t *testing.T) {
 					Name: "expected-secret",
 				},
 			},
-			Template: "templates/db-migrate-hook.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "multiple-secrets",
@@ -121,7 +123,7 @@ func TestMigrateDatabaseImagePullSecrets(t *testing.T) {
 					Name: "additional-secret",
 				},
 			},
-			Template: "templates/db-migrate-hook.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "missing-secret",
@@ -130,7 +132,7 @@ func TestMigrateDatabaseImagePullSecrets(t *testing.T) {
 				"image.secrets":              "null",
 			},
 			ExpectedImagePullSecrets: nil,
-			Template:                 "templates/db-migrate-hook.yaml",
+			Template:                 "templates/hooks.yaml",
 		},
 	}
 
@@ -178,7 +180,7 @@ func TestMigrateDatabaseLabels(t *testing.T) {
 				"application.migrateCommand": "echo migrate",
 			},
 			ExpectedLabels: nil,
-			Template:       "templates/db-migrate-hook.yaml",
+			Template:       "templates/hooks.yaml",
 		},
 		{
 			CaseName: "one label",
@@ -190,7 +192,7 @@ func TestMigrateDatabaseLabels(t *testing.T) {
 			ExpectedLabels: map[string]string{
 				"firstLabel": "expected-label",
 			},
-			Template: "templates/db-migrate-hook.yaml",
+			Template: "templates/hooks.yaml",
 		},
 		{
 			CaseName: "multiple labels",
@@ -204,7 +206,7 @@ func TestMigrateDatabaseLabels(t *testing.T) {
 				"firstLabel":  "expected-label",
 				"secondLabel": "expected-label",
 			},
-			Template: "templates/db-migrate-hook.yaml",
+			Template: "templates/hooks.yaml",
 		},
 	} {
 		t.Run(tc.CaseName, func(t *testing.T) {
@@ -234,7 +236,7 @@ func TestMigrateDatabaseLabels(t *testing.T) {
 
 func TestMigrateDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 	releaseName := "migrate-application-database-extra-envfrom"
-	templates := []string{"templates/db-migrate-hook.yaml"}
+	templates := []string{"templates/hooks.yaml"}
 
 	tcs := []struct {
 		name            string
@@ -245,6 +247,7 @@ func TestMigrateDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 			name: "with extra envfrom secret test",
 			values: map[string]string{
 				"application.migrateCommand":     "echo migrate",
+				"application.secretName":         "app-secret",
 				"extraEnvFrom[0].secretRef.name": "secret-name-test",
 			},
 			expectedEnvFrom: coreV1.EnvFromSource{
@@ -259,6 +262,7 @@ func TestMigrateDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 			name: "test with extra env from secret using templating values",
 			values: map[string]string{
 				"application.migrateCommand":     "echo migrate",
+				"application.secretName":         "app-secret",
 				"extraEnvFrom[0].secretRef.name": "secret-name-{{ .Release.Name }}",
 			},
 			expectedEnvFrom: coreV1.EnvFromSource{
@@ -282,18 +286,15 @@ func TestMigrateDatabaseTemplateWithExtraEnvFrom(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
-				require.Contains(t, deployment.Spec.Template.Spec.Containers[0].EnvFrom, tc.expectedEnvFrom)
-			}
+			job := mustGet[*batchV1.Job](t, mustDecodeObjects(t, output), releaseName+"-db-migrate")
+			require.Contains(t, job.Spec.Template.Spec.Containers[0].EnvFrom, tc.expectedEnvFrom)
 		})
 	}
 }
 
 func TestMigrateDatabaseTemplateWithExtraEnv(t *testing.T) {
 	releaseName := "migrate-application-database-extra-env"
-	templates := []string{"templates/db-migrate-hook.yaml"}
+	templates := []string{"templates/hooks.yaml"}
 
 	tcs := []struct {
 		name        string
@@ -325,11 +326,134 @@ func TestMigrateDatabaseTemplateWithExtraEnv(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
-				require.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, tc.expectedEnv)
+			job := mustGet[*batchV1.Job](t, mustDecodeObjects(t, output), releaseName+"-db-migrate")
+			require.Contains(t, job.Spec.Template.Spec.Containers[0].Env, tc.expectedEnv)
+		})
+	}
+}
+
+func TestMigrateDatabaseHookPhases(t *testing.T) {
+	releaseName := "migrate-application-database-phases"
+	templates := []string{"templates/hooks.yaml"}
+
+	tcs := []struct {
+		name   string
+		values map[string]string
+
+		expectedHook          string
+		expectedOnInstall     bool
+		expectedOnUpgrade     bool
+		expectedInitContainer bool
+
+		expectedErrorRegexp *regexp.Regexp
+	}{
+		{
+			name:                "disabled",
+			values:              map[string]string{"application.migrateOnInstall": "true"},
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/hooks.yaml in chart"),
+		},
+		{
+			name:              "upgrade",
+			values:            map[string]string{"application.migrateCommand": "rake db:migrate"},
+			expectedHook:      "pre-upgrade",
+			expectedOnUpgrade: true,
+		},
+		{
+			name: "first install and upgrade",
+			values: map[string]string{
+				"application.migrateCommand":   "rake db:migrate",
+				"application.migrateOnInstall": "true",
+			},
+			expectedHook:      "pre-install,pre-upgrade",
+			expectedOnInstall: true,
+			expectedOnUpgrade: true,
+		},
+		{
+			name: "first install with the managed database",
+			values: map[string]string{
+				"application.migrateCommand":   "rake db:migrate",
+				"application.migrateOnInstall": "true",
+				"postgresql.managed":           "true",
+			},
+			expectedErrorRegexp: regexp.MustCompile("application.migrateOnInstall can't be combined with postgresql.managed"),
+		},
+		{
+			name: "upgrade with the managed database",
+			values: map[string]string{
+				"application.migrateCommand": "rake db:migrate",
+				"postgresql.managed":         "true",
+			},
+			expectedHook:      "pre-upgrade",
+			expectedOnUpgrade: true,
+		},
+		{
+			name: "first install, waiting for the database",
+			values: map[string]string{
+				"application.migrateCommand":   "rake db:migrate",
+				"application.migrateOnInstall": "true",
+				"waitForDependencies.enabled":  "true",
+			},
+			expectedHook:          "pre-install,pre-upgrade",
+			expectedOnInstall:     true,
+			expectedOnUpgrade:     true,
+			expectedInitContainer: true,
+		},
+		{
+			name: "first install, waiting for the database of the migration only",
+			values: map[string]string{
+				"application.migrateCommand":           "rake db:migrate",
+				"application.migrateOnInstall":         "true",
+				"hooks.db-migrate.waitForDependencies": "true",
+			},
+			expectedHook:          "pre-install,pre-upgrade",
+			expectedOnInstall:     true,
+			expectedOnUpgrade:     true,
+			expectedInitContainer: true,
+		},
+		{
+			name: "upgrade, not waiting for the database",
+			values: map[string]string{
+				"application.migrateCommand":           "rake db:migrate",
+				"waitForDependencies.enabled":          "true",
+				"hooks.db-migrate.waitForDependencies": "false",
+			},
+			expectedHook:      "pre-upgrade",
+			expectedOnUpgrade: true,
+		},
+		{
+			name: "first install with initializeCommand",
+			values: map[string]string{
+				"application.migrateCommand":    "rake db:migrate",
+				"application.migrateOnInstall":  "true",
+				"application.initializeCommand": "rake db:setup",
+			},
+			expectedErrorRegexp: regexp.MustCompile("application.migrateOnInstall and application.initializeCommand both prepare the database of a new release"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &helm.Options{SetValues: tc.values}
+			output := mustRenderTemplate(t, opts, releaseName, templates, tc.expectedErrorRegexp)
+
+			if tc.expectedErrorRegexp != nil {
+				return
+			}
+
+			job := mustGet[*batchV1.Job](t, mustDecodeObjects(t, output), releaseName+"-db-migrate")
+			hook := job.Annotations["helm.sh/hook"]
+			require.Equal(t, tc.expectedHook, hook)
+			require.Equal(t, tc.expectedOnInstall, strings.Contains(hook, "-install"))
+			require.Equal(t, tc.expectedOnUpgrade, strings.Contains(hook, "-upgrade"))
+
+			initContainers := job.Spec.Template.Spec.InitContainers
+			if !tc.expectedInitContainer {
+				require.Empty(t, initContainers)
+				return
 			}
+			require.Len(t, initContainers, 1)
+			require.Equal(t, "wait-for-dependencies", initContainers[0].Name)
+			require.Equal(t, []string{"-timeout=5m", "-postgres"}, initContainers[0].Args)
 		})
 	}
 }
@@ -59,7 +59,7 @@ func TestDeploymentTemplate(t *testing.T) {
 			CaseName: "long release name",
 			Release:  strings.Repeat("r", 80),
 
-			ExpectedErrorRegexp: regexp.MustCompile("Error: release name .* length must not be longer than 53"),
+			ExpectedErrorRegexp: regexp.MustCompile("release name .* length must not be longer than 53"),
 			ExpectedLabels:      nil,
 		},
 		{
@@ -1477,6 +1477,102 @@ func TestDeploymentDatabaseUrlEnvironmentVariable(t *testing.T) {
 	}
 }
 
+func TestDeploymentTemplateWithWaitForDependencies(t *testing.T) {
+	releaseName := "deployment-wait-for-dependencies-test"
+	templates := []string{"templates/deployment.yaml"}
+
+	tcs := []struct {
+		name                   string
+		values                 map[string]string
+		expectedInitContainers []coreV1.Container
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name: "database from the secret and other dependencies before the init containers",
+			values: map[string]string{
+				"waitForDependencies.enabled":         "true",
+				"waitForDependencies.dependencies[0]": "tcp://redis:6379",
+				"waitForDependencies.dependencies[1]": "http://search:9200/_cluster/health",
+				"application.secretName":              "app-secret",
+				"initContainers[0].name":              "myservice",
+				"initContainers[0].image":             "myimage:1",
+			},
+			expectedInitContainers: []coreV1.Container{
+				{
+					Name:            "wait-for-dependencies",
+					Image:           "ghcr.io/sshoc/gl-autodevops-minimal-port/wait-for:latest",
+					ImagePullPolicy: coreV1.PullAlways,
+					Args:            []string{"-timeout=5m", "-postgres", "tcp://redis:6379", "http://search:9200/_cluster/health"},
+					EnvFrom: []coreV1.EnvFromSource{
+						{SecretRef: &coreV1.SecretEnvSource{LocalObjectReference: coreV1.LocalObjectReference{Name: "app-secret"}}},
+					},
+				},
+				{
+					Name:  "myservice",
+					Image: "myimage:1",
+				},
+			},
+		},
+		{
+			name: "DATABASE_URL, image and timeout",
+			values: map[string]string{
+				"waitForDependencies.enabled":                 "true",
+				"waitForDependencies.image.repository":        "registry.example.com/wait-for",
+				"waitForDependencies.image.tag":               "1a2b3c4",
+				"waitForDependencies.timeout":                 "90s",
+				"waitForDependencies.resources.limits.memory": "32Mi",
+				"application.database_url":                    "postgres://user:secret@db:5432/app",
+				"containerSecurityContext.runAsNonRoot":       "true",
+			},
+			expectedInitContainers: []coreV1.Container{
+				{
+					Name:            "wait-for-dependencies",
+					Image:           "registry.example.com/wait-for:1a2b3c4",
+					ImagePullPolicy: coreV1.PullIfNotPresent,
+					Args:            []string{"-timeout=90s", "-postgres"},
+					Env:             []coreV1.EnvVar{{Name: "DATABASE_URL", Value: "postgres://user:secret@db:5432/app"}},
+					Resources: coreV1.ResourceRequirements{
+						Limits: coreV1.ResourceList{"memory": resource.MustParse("32Mi")},
+					},
+					SecurityContext: &coreV1.SecurityContext{RunAsNonRoot: &[]bool{true}[0]},
+				},
+			},
+		},
+		{
+			name: "without the database, with a pull policy",
+			values: map[string]string{
+				"waitForDependencies.enabled":          "true",
+				"waitForDependencies.image.pullPolicy": "Never",
+				"waitForDependencies.postgres":         "false",
+				"waitForDependencies.dependencies[0]":  "redis:6379",
+			},
+			expectedInitContainers: []coreV1.Container{
+				{
+					Name:            "wait-for-dependencies",
+					Image:           "ghcr.io/sshoc/gl-autodevops-minimal-port/wait-for:latest",
+					ImagePullPolicy: coreV1.PullNever,
+					Args:            []string{"-timeout=5m", "redis:6379"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &helm.Options{
+				SetValues: tc.values,
+			}
+			output := mustRenderTemplate(t, opts, releaseName, templates, nil)
+
+			deployment := new(appsV1.Deployment)
+			helm.UnmarshalK8SYaml(t, output, deployment)
+			require.Equal(t, tc.expectedInitContainers, deployment.Spec.Template.Spec.InitContainers)
+		})
+	}
+}
+
 func TestDeploymentTemplateWithExtraEnvFrom(t *testing.T) {
 	releaseName := "deployment-with-extra-envfrom-test"
 	templates := []string{"templates/deployment.yaml"}
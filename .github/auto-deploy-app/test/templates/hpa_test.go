@@ -9,6 +9,9 @@ import (
 	"github.com/stretchr/testify/require"
 	autoscalingV1 "k8s.io/api/autoscaling/v1"
 	autoscalingV2 "k8s.io/api/autoscaling/v2"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestHPA_AutoscalingV1(t *testing.T) {
@@ -20,6 +23,7 @@ func TestHPA_AutoscalingV1(t *testing.T) {
 		values map[string]string
 
 		expectedName        string
+		expectedTargetName  string
 		expectedMinReplicas int32
 		expectedMaxReplicas int32
 		expectedTargetCPU   int32
@@ -29,13 +33,13 @@ func TestHPA_AutoscalingV1(t *testing.T) {
 	}{
 		{
 			name:                "defaults",
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/hpa.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/hpa.yaml in chart"),
 			ExpectedLabels:      nil,
 		},
 		{
 			name:                "with hpa enabled, no requests",
 			values:              map[string]string{"hpa.enabled": "true"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/hpa.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/hpa.yaml in chart"),
 			ExpectedLabels:      nil,
 		},
 		{
@@ -45,6 +49,7 @@ func TestHPA_AutoscalingV1(t *testing.T) {
 				"resources.requests.cpu": "500",
 			},
 			expectedName:        "hpa-test-auto-deploy",
+			expectedTargetName:  "hpa-test",
 			expectedMinReplicas: 1,
 			expectedMaxReplicas: 5,
 			expectedTargetCPU:   80,
@@ -58,6 +63,7 @@ func TestHPA_AutoscalingV1(t *testing.T) {
 				"extraLabels.firstLabel":    "expected-label",
 			},
 			expectedName:        "hpa-test-auto-deploy",
+			expectedTargetName:  "hpa-test",
 			expectedMinReplicas: 1,
 			expectedMaxReplicas: 5,
 			expectedTargetCPU:   80,
@@ -65,6 +71,20 @@ func TestHPA_AutoscalingV1(t *testing.T) {
 				"firstLabel": "expected-label",
 			},
 		},
+		{
+			name:                "with hpa enabled and requests, canary track",
+			values:              map[string]string{
+				"hpa.enabled": "true",
+				"resources.requests.cpu": "500",
+				"application.track":      "canary",
+			},
+			expectedName:        "hpa-test-auto-deploy",
+			expectedTargetName:  "hpa-test-canary",
+			expectedMinReplicas: 1,
+			expectedMaxReplicas: 5,
+			expectedTargetCPU:   80,
+			ExpectedLabels:      nil,
+		},
 	}
 
 	for _, tc := range tcs {
@@ -81,6 +101,7 @@ func TestHPA_AutoscalingV1(t *testing.T) {
 			hpa := new(autoscalingV1.HorizontalPodAutoscaler)
 			helm.UnmarshalK8SYaml(t, output, hpa)
 			require.Equal(t, tc.expectedName, hpa.ObjectMeta.Name)
+			require.Equal(t, tc.expectedTargetName, hpa.Spec.ScaleTargetRef.Name)
 			require.Equal(t, tc.expectedMinReplicas, *hpa.Spec.MinReplicas)
 			require.Equal(t, tc.expectedMaxReplicas, hpa.Spec.MaxReplicas)
 			require.Equal(t, tc.expectedTargetCPU, *hpa.Spec.TargetCPUUtilizationPercentage)
@@ -108,7 +129,7 @@ func TestHPA_AutoscalingV2(t *testing.T) {
 	}{
 		{
 			name:                "defaults",
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/hpa.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/hpa.yaml in chart"),
 		},
 		{
 			name: "with hpa enabled, and both metrics and requests defined",
@@ -156,3 +177,263 @@ resources:
 		})
 	}
 }
+
+func TestWorkerHPA_AutoscalingV1(t *testing.T) {
+	templates := []string{"templates/worker-hpa.yaml"}
+	releaseName := "worker-hpa-test"
+
+	tcs := []struct {
+		name   string
+		values map[string]string
+
+		expectedWorkers     []string
+		expectedMinReplicas int32
+		expectedMaxReplicas int32
+		expectedTargetCPU   int32
+
+		expectedErrorRegexp *regexp.Regexp
+	}{
+		{
+			name:                "defaults",
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/worker-hpa.yaml in chart"),
+		},
+		{
+			name: "with hpa enabled, no requests",
+			values: map[string]string{
+				"workers.worker1.command[0]":  "echo",
+				"workers.worker1.hpa.enabled": "true",
+			},
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/worker-hpa.yaml in chart"),
+		},
+		{
+			name: "with hpa enabled and requests of the worker",
+			values: map[string]string{
+				"workers.worker1.command[0]":             "echo",
+				"workers.worker1.hpa.enabled":            "true",
+				"workers.worker1.resources.requests.cpu": "100m",
+				"workers.worker2.command[0]":             "echo",
+				"workers.worker2.resources.requests.cpu": "100m",
+				"workers.worker2.hpa.maxReplicas":        "3",
+			},
+			expectedWorkers:     []string{"worker1"},
+			expectedMinReplicas: 1,
+			expectedMaxReplicas: 5,
+			expectedTargetCPU:   80,
+		},
+		{
+			name: "with hpa enabled and top level requests",
+			values: map[string]string{
+				"resources.requests.cpu":                             "500m",
+				"workers.worker1.command[0]":                         "echo",
+				"workers.worker1.hpa.enabled":                        "true",
+				"workers.worker1.hpa.minReplicas":                    "2",
+				"workers.worker1.hpa.maxReplicas":                    "10",
+				"workers.worker1.hpa.targetCPUUtilizationPercentage": "60",
+				"workers.worker2.command[0]":                         "echo",
+				"workers.worker2.hpa.enabled":                        "true",
+				"workers.worker2.hpa.minReplicas":                    "2",
+				"workers.worker2.hpa.maxReplicas":                    "10",
+				"workers.worker2.hpa.targetCPUUtilizationPercentage": "60",
+			},
+			expectedWorkers:     []string{"worker1", "worker2"},
+			expectedMinReplicas: 2,
+			expectedMaxReplicas: 10,
+			expectedTargetCPU:   60,
+		},
+		{
+			name: "with hpa enabled and requests, initializeCommand defined",
+			values: map[string]string{
+				"application.initializeCommand":          "echo initialize",
+				"workers.worker1.command[0]":             "echo",
+				"workers.worker1.hpa.enabled":            "true",
+				"workers.worker1.resources.requests.cpu": "100m",
+			},
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/worker-hpa.yaml in chart"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &helm.Options{SetValues: tc.values}
+			output := mustRenderTemplate(t, opts, releaseName, templates, tc.expectedErrorRegexp)
+
+			if tc.expectedErrorRegexp != nil {
+				return
+			}
+
+			hpas := mustGetAll[*autoscalingV1.HorizontalPodAutoscaler](t, mustDecodeObjects(t, output))
+			require.Len(t, hpas, len(tc.expectedWorkers))
+			for i, hpa := range hpas {
+				name := releaseName + "-" + tc.expectedWorkers[i]
+				require.Equal(t, name, hpa.Name)
+				require.Equal(t, expectedWorkerLabels(releaseName, releaseName, tc.expectedWorkers[i]), hpa.Labels)
+				require.Equal(t, autoscalingV1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: name}, hpa.Spec.ScaleTargetRef)
+				require.Equal(t, tc.expectedMinReplicas, *hpa.Spec.MinReplicas)
+				require.Equal(t, tc.expectedMaxReplicas, hpa.Spec.MaxReplicas)
+				require.Equal(t, tc.expectedTargetCPU, *hpa.Spec.TargetCPUUtilizationPercentage)
+			}
+		})
+	}
+}
+
+func TestWorkerHPA_AutoscalingV2(t *testing.T) {
+	templates := []string{"templates/worker-hpa.yaml"}
+	releaseName := "worker-hpa-test"
+	scaleDownWindow := int32(300)
+	averageUtilization := int32(70)
+
+	tcs := []struct {
+		name   string
+		values string
+
+		expectedMetrics  []autoscalingV2.MetricSpec
+		expectedBehavior *autoscalingV2.HorizontalPodAutoscalerBehavior
+
+		expectedErrorRegexp *regexp.Regexp
+	}{
+		{
+			name: "with external and object metrics, no requests",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    hpa:
+      enabled: true
+      metrics:
+      - type: External
+        external:
+          metric:
+            name: queue_messages_ready
+            selector:
+              matchLabels:
+                queue: default
+          target:
+            type: AverageValue
+            averageValue: 30
+      - type: Object
+        object:
+          describedObject:
+            apiVersion: networking.k8s.io/v1
+            kind: Ingress
+            name: main-route
+          metric:
+            name: requests-per-second
+          target:
+            type: Value
+            value: 2k
+`,
+			expectedMetrics: []autoscalingV2.MetricSpec{
+				{
+					Type: autoscalingV2.ExternalMetricSourceType,
+					External: &autoscalingV2.ExternalMetricSource{
+						Metric: autoscalingV2.MetricIdentifier{
+							Name:     "queue_messages_ready",
+							Selector: &metaV1.LabelSelector{MatchLabels: map[string]string{"queue": "default"}},
+						},
+						Target: autoscalingV2.MetricTarget{Type: autoscalingV2.AverageValueMetricType, AverageValue: resourcePtr("30")},
+					},
+				},
+				{
+					Type: autoscalingV2.ObjectMetricSourceType,
+					Object: &autoscalingV2.ObjectMetricSource{
+						DescribedObject: autoscalingV2.CrossVersionObjectReference{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "main-route"},
+						Metric:          autoscalingV2.MetricIdentifier{Name: "requests-per-second"},
+						Target:          autoscalingV2.MetricTarget{Type: autoscalingV2.ValueMetricType, Value: resourcePtr("2k")},
+					},
+				},
+			},
+		},
+		{
+			name: "with behavior and requests, CPU target",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    resources:
+      requests:
+        cpu: 100m
+    hpa:
+      enabled: true
+      targetCPUUtilizationPercentage: 70
+      behavior:
+        scaleDown:
+          stabilizationWindowSeconds: 300
+          policies:
+          - type: Pods
+            value: 1
+            periodSeconds: 60
+        scaleUp:
+          selectPolicy: Max
+          policies:
+          - type: Percent
+            value: 100
+            periodSeconds: 15
+`,
+			expectedMetrics: []autoscalingV2.MetricSpec{
+				{
+					Type: autoscalingV2.ResourceMetricSourceType,
+					Resource: &autoscalingV2.ResourceMetricSource{
+						Name:   coreV1.ResourceCPU,
+						Target: autoscalingV2.MetricTarget{Type: autoscalingV2.UtilizationMetricType, AverageUtilization: &averageUtilization},
+					},
+				},
+			},
+			expectedBehavior: &autoscalingV2.HorizontalPodAutoscalerBehavior{
+				ScaleUp: &autoscalingV2.HPAScalingRules{
+					SelectPolicy: selectPolicyPtr(autoscalingV2.MaxChangePolicySelect),
+					Policies:     []autoscalingV2.HPAScalingPolicy{{Type: autoscalingV2.PercentScalingPolicy, Value: 100, PeriodSeconds: 15}},
+				},
+				ScaleDown: &autoscalingV2.HPAScalingRules{
+					StabilizationWindowSeconds: &scaleDownWindow,
+					Policies:                   []autoscalingV2.HPAScalingPolicy{{Type: autoscalingV2.PodsScalingPolicy, Value: 1, PeriodSeconds: 60}},
+				},
+			},
+		},
+		{
+			name: "with behavior, no requests",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    hpa:
+      enabled: true
+      behavior:
+        scaleDown:
+          stabilizationWindowSeconds: 300
+`,
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/worker-hpa.yaml in chart"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.CreateTemp("", "")
+			defer os.Remove(f.Name())
+			require.NoError(t, err)
+			f.WriteString(tc.values)
+
+			opts := &helm.Options{ValuesFiles: []string{f.Name()}}
+			output := mustRenderTemplate(t, opts, releaseName, templates, tc.expectedErrorRegexp)
+
+			if tc.expectedErrorRegexp != nil {
+				return
+			}
+
+			hpa := mustGet[*autoscalingV2.HorizontalPodAutoscaler](t, mustDecodeObjects(t, output), releaseName+"-worker1")
+			require.Equal(t, releaseName+"-worker1", hpa.Spec.ScaleTargetRef.Name)
+			require.Equal(t, int32(1), *hpa.Spec.MinReplicas)
+			require.Equal(t, int32(5), hpa.Spec.MaxReplicas)
+			require.Equal(t, tc.expectedMetrics, hpa.Spec.Metrics)
+			require.Equal(t, tc.expectedBehavior, hpa.Spec.Behavior)
+		})
+	}
+}
+
+func resourcePtr(quantity string) *resource.Quantity {
+	q := resource.MustParse(quantity)
+	return &q
+}
+
+func selectPolicyPtr(policy autoscalingV2.ScalingPolicySelect) *autoscalingV2.ScalingPolicySelect {
+	return &policy
+}
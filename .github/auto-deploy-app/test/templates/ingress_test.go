@@ -196,27 +196,27 @@ func TestIngressTemplate_Disable(t *testing.T) {
 		{
 			name:                "with service disabled and track stable",
 			values:              map[string]string{"service.enabled": "false", "application.track": "stable"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/ingress.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/ingress.yaml in chart"),
 		},
 		{
 			name:                "with service disabled and track non-stable",
 			values:              map[string]string{"service.enabled": "false", "application.track": "non-stable"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/ingress.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/ingress.yaml in chart"),
 		},
 		{
 			name:                "with ingress disabled",
 			values:              map[string]string{"ingress.enabled": "false"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/ingress.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/ingress.yaml in chart"),
 		},
 		{
 			name:                "with ingress enabled and service disabled",
 			values:              map[string]string{"ingress.enabled": "true", "service.enabled": "false"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/ingress.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/ingress.yaml in chart"),
 		},
 		{
 			name:                "with ingress disabled and service enabled and track stable",
 			values:              map[string]string{"ingress.enabled": "false", "service.enabled": "true", "application.track": "stable"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/ingress.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/ingress.yaml in chart"),
 		},
 	}
 
@@ -298,7 +298,7 @@ func TestIngressTemplate_TLSSecret(t *testing.T) {
 		},
 		{
 			name:               "use the provided secretName",
-			values:             map[string]string{"ingress.useDefaultSecret": "false"},
+			values:             map[string]string{"ingress.tls.useDefaultSecret": "false"},
 			expectedsecretname: releaseName + "-auto-deploy-tls",
 		},
 	}
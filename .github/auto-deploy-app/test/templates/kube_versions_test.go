@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// kubeAPI is a built-in API the chart may emit, with the Kubernetes minor versions that serve it.
+type kubeAPI struct {
+	gvk     string // group/version/Kind, as reported by the discovery API
+	since   int    // first minor version serving the API
+	removed int    // first minor version no longer serving the API, 0 if it is still served
+}
+
+// kubeAPIs lists every API the chart may emit. Versions of the same kind are ordered newest first.
+// An API with a removal version is deprecated, the chart must not use it once its replacement is served.
+// See https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var kubeAPIs = []kubeAPI{
+	{gvk: "v1/Service", since: 0},
+	{gvk: "v1/ServiceAccount", since: 0},
+	{gvk: "v1/PersistentVolumeClaim", since: 0},
+	{gvk: "v1/Pod", since: 0},
+	{gvk: "apps/v1/Deployment", since: 9},
+	{gvk: "batch/v1/Job", since: 0},
+	{gvk: "batch/v1/CronJob", since: 21},
+	{gvk: "batch/v1beta1/CronJob", since: 8, removed: 25},
+	{gvk: "policy/v1/PodDisruptionBudget", since: 21},
+	{gvk: "policy/v1beta1/PodDisruptionBudget", since: 5, removed: 25},
+	{gvk: "autoscaling/v2/HorizontalPodAutoscaler", since: 23},
+	{gvk: "autoscaling/v2beta2/HorizontalPodAutoscaler", since: 12, removed: 26},
+	{gvk: "autoscaling/v1/HorizontalPodAutoscaler", since: 0},
+	{gvk: "networking.k8s.io/v1/Ingress", since: 19},
+	{gvk: "networking.k8s.io/v1beta1/Ingress", since: 14, removed: 22},
+	{gvk: "extensions/v1beta1/Ingress", since: 1, removed: 22},
+	{gvk: "networking.k8s.io/v1/NetworkPolicy", since: 7},
+	{gvk: "rbac.authorization.k8s.io/v1/Role", since: 8},
+	{gvk: "rbac.authorization.k8s.io/v1/RoleBinding", since: 8},
+}
+
+// kubeMinorVersions are the Kubernetes 1.x versions the chart is rendered against.
+var kubeMinorVersions = []int{19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31}
+
+func (a kubeAPI) kind() string {
+	return a.gvk[strings.LastIndex(a.gvk, "/")+1:]
+}
+
+func (a kubeAPI) servedBy(minor int) bool {
+	return minor >= a.since && (a.removed == 0 || minor < a.removed)
+}
+
+// kubeVersionHelmArgs returns the helm arguments that make the chart render like against a cluster
+// of the given minor version.
+func kubeVersionHelmArgs(minor int) []string {
+	args := []string{"--kube-version", fmt.Sprintf("1.%d.0", minor)}
+	for _, api := range kubeAPIs {
+		if api.servedBy(minor) {
+			args = append(args, "--api-versions", api.gvk)
+		}
+	}
+	return args
+}
+
+func TestKubeVersionMatrix(t *testing.T) {
+	tcs := []struct {
+		name   string
+		values map[string]string
+		files  []string
+	}{
+		{
+			name:  "web with workers and cronjobs",
+			files: []string{testdataPath + "/web-full.yaml", testdataPath + "/workers-cronjobs.yaml"},
+		},
+		{
+			name:  "hpa with metrics",
+			files: []string{testdataPath + "/web-full.yaml"},
+			values: map[string]string{
+				"hpa.metrics[0].type":                               "Resource",
+				"hpa.metrics[0].resource.name":                      "cpu",
+				"hpa.metrics[0].resource.target.type":               "Utilization",
+				"hpa.metrics[0].resource.target.averageUtilization": "80",
+			},
+		},
+		{
+			name:  "database initialization",
+			files: []string{testdataPath + "/db-initialize.yaml"},
+		},
+	}
+
+	for _, minor := range kubeMinorVersions {
+		for _, tc := range tcs {
+			t.Run(fmt.Sprintf("1.%d/%s", minor, tc.name), func(t *testing.T) {
+				opts := &helm.Options{
+					ValuesFiles: tc.files,
+					SetValues:   tc.values,
+				}
+				output := mustRenderTemplate(t, opts, "kube-version", nil, nil, kubeVersionHelmArgs(minor)...)
+
+				objects := mustDecodeObjects(t, output)
+				require.NotEmpty(t, objects.objects)
+				for _, obj := range objects.objects {
+					if _, ok := obj.(*unstructured.Unstructured); ok {
+						continue // custom resources are served by whatever installed their CRD
+					}
+					gvk := obj.GetObjectKind().GroupVersionKind()
+					requireServedAPI(t, minor, objectKey(obj), gvk.GroupVersion().String()+"/"+gvk.Kind)
+				}
+			})
+		}
+	}
+}
+
+// requireServedAPI checks that the emitted API is served by the given minor version, and that a
+// deprecated API is only emitted while the cluster doesn't serve its replacement yet.
+func requireServedAPI(t *testing.T, minor int, key string, gvk string) {
+	t.Helper()
+
+	kind := gvk[strings.LastIndex(gvk, "/")+1:]
+	newer := ""
+	for _, api := range kubeAPIs {
+		if api.kind() != kind {
+			continue
+		}
+		if api.gvk != gvk {
+			if newer == "" && api.servedBy(minor) {
+				newer = api.gvk
+			}
+			continue
+		}
+		require.Truef(t, api.servedBy(minor), "%s is rendered as %s, which is not served by Kubernetes 1.%d", key, gvk, minor)
+		if api.removed != 0 {
+			require.Emptyf(t, newer, "%s is rendered as the deprecated %s, but Kubernetes 1.%d serves %s", key, gvk, minor, newer)
+		}
+		return
+	}
+	require.Failf(t, "unknown API", "%s is rendered as %s, add it to kubeAPIs", key, gvk)
+}
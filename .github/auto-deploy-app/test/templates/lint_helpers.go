@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxLineLength is the longest line allowed in rendered output, unless the line is a single long word like a URL
+const maxLineLength = 160
+
+// lintYAML checks the layout of rendered output with the rules the chart has always been held to:
+// two spaces per indentation level, sequences not indented below their key (the kubectl style),
+// no blank lines, not even in block scalars, and lines of at most maxLineLength characters.
+// Trailing spaces are allowed, because empty values are rendered without an if around every option.
+// It returns one problem per offending line, prefixed with the line number.
+func lintYAML(output string) []string {
+	var problems []string
+	for i, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		problems = append(problems, lintLine(i+1, line)...)
+	}
+
+	decoder := yaml.NewDecoder(strings.NewReader(output))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return append(problems, fmt.Sprintf("syntax error: %s", err))
+		}
+		problems = append(problems, lintIndentation(&doc, 0)...)
+	}
+	return problems
+}
+
+func lintLine(number int, line string) []string {
+	var problems []string
+	if strings.TrimSpace(line) == "" {
+		problems = append(problems, fmt.Sprintf("line %d: empty line", number))
+	}
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	if strings.Contains(indent, "\t") {
+		problems = append(problems, fmt.Sprintf("line %d: tab in indentation", number))
+	}
+	if len(line) > maxLineLength && !isNonBreakable(line) {
+		problems = append(problems, fmt.Sprintf("line %d: line too long (%d > %d characters)", number, len(line), maxLineLength))
+	}
+	return problems
+}
+
+// isNonBreakable reports whether a line consists of a single word after its indentation, list marker
+// or comment marker, which can't be wrapped.
+func isNonBreakable(line string) bool {
+	word := strings.TrimLeft(line, " ")
+	for _, marker := range []string{"- ", "# "} {
+		word = strings.TrimPrefix(word, marker)
+	}
+	return !strings.ContainsAny(word, " \t")
+}
+
+// lintIndentation checks that the block collections below node are indented like their parent expects.
+// indent is the column, counted from 0, that node has to start at when it is a block collection.
+func lintIndentation(node *yaml.Node, indent int) []string {
+	var problems []string
+	if node.Style&yaml.FlowStyle != 0 {
+		return nil
+	}
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		if found := node.Column - 1; found != indent {
+			problems = append(problems, fmt.Sprintf("line %d: wrong indentation: expected %d but found %d", node.Line, indent, found))
+			indent = found
+		}
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			problems = append(problems, lintIndentation(child, 0)...)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if found := key.Column - 1; found != indent {
+				problems = append(problems, fmt.Sprintf("line %d: wrong indentation: expected %d but found %d", key.Line, indent, found))
+			}
+			switch {
+			case value.Line == key.Line:
+				// a scalar or flow collection after the key
+			case value.Kind == yaml.SequenceNode:
+				problems = append(problems, lintIndentation(value, indent)...)
+			default:
+				problems = append(problems, lintIndentation(value, indent+2)...)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			problems = append(problems, lintIndentation(item, indent+2)...)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintYAML(t *testing.T) {
+	tcs := []struct {
+		name   string
+		output string
+
+		expectedProblems []string
+	}{
+		{
+			name: "kubectl style",
+			output: `---
+# Source: auto-deploy-app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations: {app.gitlab.com/app: web}
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        args: ["-c", "rake"]
+        env:
+        - name: EMPTY
+          value:
+        command:
+        - /bin/sh
+        - - nested
+      script: |
+        echo hello
+          echo indented
+---
+# Source: auto-deploy-app/templates/hpa.yaml
+`,
+		},
+		{
+			name: "indented sequence",
+			output: `---
+spec:
+  containers:
+    - name: web
+`,
+			expectedProblems: []string{"line 4: wrong indentation: expected 2 but found 4"},
+		},
+		{
+			name: "indented by four spaces",
+			output: `---
+spec:
+    replicas: 1
+    template:
+        metadata: {}
+`,
+			expectedProblems: []string{
+				"line 3: wrong indentation: expected 2 but found 4",
+				"line 5: wrong indentation: expected 6 but found 8",
+			},
+		},
+		{
+			name: "sequence item indented wrongly",
+			output: `---
+args:
+-   /bin/sh
+-   name: web
+`,
+			expectedProblems: []string{"line 4: wrong indentation: expected 2 but found 4"},
+		},
+		{
+			name: "blank lines",
+			output: `---
+metadata:
+  name: web
+
+spec:
+  ` + `
+  replicas: 1
+`,
+			expectedProblems: []string{"line 4: empty line", "line 6: empty line"},
+		},
+		{
+			name:   "tab in indentation",
+			output: "---\nmetadata:\n  name: web\n\t# comment\n",
+			expectedProblems: []string{
+				"line 4: tab in indentation",
+				"syntax error: yaml: line 3: found a tab character that violates indentation",
+			},
+		},
+		{
+			name: "long lines",
+			output: `---
+metadata:
+  annotations:
+    description: ` + strings.Repeat("lorem ipsum ", 14) + `
+args:
+- https://example.com/` + strings.Repeat("a", 160) + `
+`,
+			expectedProblems: []string{"line 4: line too long (185 > 160 characters)"},
+		},
+		{
+			name: "syntax error",
+			output: `---
+metadata:
+  name: web
+ labels: {}
+`,
+			expectedProblems: []string{"syntax error: yaml: line 1: did not find expected key"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedProblems, lintYAML(tc.output))
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"github.com/stretchr/testify/require"
+	appsV1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The fuzzers render the whole chart for every input, so run them one at a time, e.g.
+// `go test ./templates -run '^$' -fuzz FuzzNameHelpers -fuzztime 2m`.
+// Without -fuzz only the seed corpus is rendered.
+
+var (
+	// helm itself rejects release names that are longer or contain other characters
+	fuzzReleaseNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,51}[a-z0-9])?$`)
+	// values that end up in names, trailing dashes and any length are up to the helpers to fix
+	fuzzNamePartRegexp = regexp.MustCompile(`^[a-z0-9][-a-z0-9]{0,99}$`)
+	// the track is a label value as is, so it has to be a valid one to begin with
+	fuzzTrackRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+)
+
+// cronJobNameMaxLength is the limit for CronJob names, the Job controller appends 11 characters to them
+const cronJobNameMaxLength = 52
+
+func FuzzNameHelpers(f *testing.F) {
+	f.Add("production", "", "", "stable", "sidekiq", "cleanup")
+	f.Add("review-feature-branch", "my-app", "", "canary", "mailer", "report")
+	f.Add(strings.Repeat("r", 53), "", "", "stable", "worker", "job")
+	f.Add("production", strings.Repeat("o", 62)+"-", "", "stable", "worker", "job")
+	f.Add("production", strings.Repeat("o", 61)+"--x", "", "stable", "worker", "job")
+	f.Add("production", "", strings.Repeat("n", 23)+"--", "stable", "worker", "job")
+	f.Add(strings.Repeat("r", 48)+"-app", "", "app", "stable", "worker", "job")
+	f.Add("production", "", "", strings.Repeat("t", 63), "worker", "job")
+	f.Add("production", strings.Repeat("o", 40), "", "canary", strings.Repeat("w", 30)+"--", strings.Repeat("c", 30)+"-")
+	f.Add(strings.Repeat("r", 53), strings.Repeat("o", 63), "", "stable", strings.Repeat("w", 100), strings.Repeat("c", 100))
+
+	f.Fuzz(func(t *testing.T, releaseName, releaseOverride, nameOverride, track, workerName, cronjobName string) {
+		if !fuzzReleaseNameRegexp.MatchString(releaseName) ||
+			(releaseOverride != "" && !fuzzNamePartRegexp.MatchString(releaseOverride)) ||
+			(nameOverride != "" && !fuzzNamePartRegexp.MatchString(nameOverride)) ||
+			!fuzzTrackRegexp.MatchString(track) ||
+			!fuzzNamePartRegexp.MatchString(workerName) ||
+			!fuzzNamePartRegexp.MatchString(cronjobName) {
+			t.Skip("input is not a valid release name or name part")
+		}
+
+		values := map[string]string{
+			"application.track":                             track,
+			"application.initializeCommand":                 "",
+			"application.migrateCommand":                    "rake db:migrate",
+			"workers." + workerName + ".replicaCount":       "1",
+			"cronjobs." + cronjobName + ".schedule":         "*/5 * * * *",
+			"persistence.enabled":                           "true",
+			"persistence.volumes[0].name":                   "data",
+			"persistence.volumes[0].mount.path":             "/data",
+			"persistence.volumes[0].claim.accessMode":       "ReadWriteOnce",
+			"persistence.volumes[0].claim.size":             "1Gi",
+			"hpa.enabled":                                   "true",
+			"resources.requests.cpu":                        "100m",
+			"podDisruptionBudget.enabled":                   "true",
+			"networkPolicy.enabled":                         "true",
+			"postgresql.managed":                            "true",
+			"postgresql.managedClassSelector.matchLabels.a": "b",
+		}
+		if releaseOverride != "" {
+			values["releaseOverride"] = releaseOverride
+		}
+		if nameOverride != "" {
+			values["nameOverride"] = nameOverride
+		}
+
+		opts := &helm.Options{SetValues: values, Logger: nil}
+		output := mustRenderTemplate(t, opts, releaseName, nil, nil)
+
+		objects := mustDecodeObjects(t, output)
+		require.NotEmpty(t, objects.objects)
+		for _, obj := range objects.objects {
+			requireValidNames(t, obj)
+		}
+
+		// workers and cronjobs with different keys must not collide after shortening
+		require.Len(t, mustGetAll[*appsV1.Deployment](t, objects), 2)
+	})
+}
+
+// requireValidNames checks the name of an object, the names of its containers and all label values in it.
+func requireValidNames(t *testing.T, obj runtime.Object) {
+	t.Helper()
+
+	key := objectKey(obj)
+	accessor, err := meta.Accessor(obj)
+	require.NoError(t, err)
+
+	name := accessor.GetName()
+	require.Emptyf(t, validation.IsDNS1123Label(name), "%s has an invalid name", key)
+	if obj.GetObjectKind().GroupVersionKind().Kind == "CronJob" {
+		require.LessOrEqualf(t, len(name), cronJobNameMaxLength, "%s has a name that is too long for a CronJob", key)
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	require.NoError(t, err)
+	walkNames(content, "", func(field string, value string) {
+		switch field {
+		case "labels", "matchLabels":
+			require.Emptyf(t, validation.IsValidLabelValue(value), "%s has an invalid label value %q", key, value)
+		case "containers", "initContainers":
+			require.Emptyf(t, validation.IsDNS1123Label(value), "%s has an invalid container name %q", key, value)
+		}
+	})
+}
+
+// walkNames calls fn with the values of label maps and the names of containers found anywhere in content.
+func walkNames(content interface{}, field string, fn func(field string, value string)) {
+	switch c := content.(type) {
+	case map[string]interface{}:
+		for k, v := range c {
+			if s, ok := v.(string); ok && (field == "labels" || field == "matchLabels") {
+				fn(field, s)
+				continue
+			}
+			if s, ok := v.(string); ok && k == "name" && (field == "containers" || field == "initContainers") {
+				fn(field, s)
+				continue
+			}
+			walkNames(v, k, fn)
+		}
+	case []interface{}:
+		for _, v := range c {
+			walkNames(v, field, fn)
+		}
+	}
+}
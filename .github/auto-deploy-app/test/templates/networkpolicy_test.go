@@ -39,7 +39,7 @@ func TestNetworkPolicy(t *testing.T) {
 	}{
 		{
 			name:                "disabled by default",
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/network-policy.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/network-policy.yaml in chart"),
 		},
 		{
 			name:        "with default policy",
@@ -2,7 +2,6 @@ package main
 
 import (
 	"regexp"
-	"strings"
 	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/helm"
@@ -68,7 +67,7 @@ func TestPvcTemplate_Single(t *testing.T) {
 		{
 			name:                "when disabled",
 			values:              map[string]string{"persistence.enabled": "false"},
-			expectedErrorRegexp: regexp.MustCompile("error while running command: exit status 1; Error: could not find template templates/pvc.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/pvc.yaml in chart"),
 		},
 	}
 
@@ -157,11 +156,13 @@ func TestPvcTemplate_Multiple(t *testing.T) {
 			}
 			output := mustRenderTemplate(t, opts, releaseName, templates, tc.expectedErrorRegexp)
 
-			pvcList := strings.Split(output, "---")
-			for i, pvcOutput := range pvcList[1:] {
-				pvc := new(coreV1.PersistentVolumeClaim)
-				helm.UnmarshalK8SYaml(t, pvcOutput, pvc)
+			if tc.expectedErrorRegexp != nil {
+				return
+			}
 
+			pvcs := mustGetAll[*coreV1.PersistentVolumeClaim](t, mustDecodeObjects(t, output))
+			require.Len(t, pvcs, len(tc.expectedPVCs))
+			for i, pvc := range pvcs {
 				require.Equal(t, tc.expectedPVCs[i].AccessModes, pvc.Spec.AccessModes)
 				require.Equal(t, tc.expectedPVCs[i].Resources.Requests["storage"], pvc.Spec.Resources.Requests["storage"])
 				require.Equal(t, tc.expectedPVCs[i].StorageClassName, pvc.Spec.StorageClassName)
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	appsV1 "k8s.io/api/apps/v1"
+	autoscalingV1 "k8s.io/api/autoscaling/v1"
+	autoscalingV2 "k8s.io/api/autoscaling/v2"
+	autoscalingV2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	coreV1 "k8s.io/api/core/v1"
+	extensionsV1beta1 "k8s.io/api/extensions/v1beta1"
+	netV1 "k8s.io/api/networking/v1"
+	netV1beta1 "k8s.io/api/networking/v1beta1"
+	policyV1 "k8s.io/api/policy/v1"
+	policyV1beta1 "k8s.io/api/policy/v1beta1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// referenceChecker collects the references between the objects of a rendered release that don't resolve.
+type referenceChecker struct {
+	objects  *renderedObjects
+	external map[string]bool
+	pods     []podTemplate
+	problems []string
+}
+
+// podTemplate is the template of the pods a workload object creates.
+type podTemplate struct {
+	owner  string // kind/name of the workload
+	labels map[string]string
+	spec   coreV1.PodSpec
+}
+
+// mustResolveReferences fails the test for every reference between the rendered objects that doesn't resolve.
+// See referenceProblems.
+func mustResolveReferences(t *testing.T, objects *renderedObjects, external ...string) {
+	t.Helper()
+
+	for _, problem := range referenceProblems(objects, external...) {
+		t.Error(problem)
+	}
+}
+
+// referenceProblems checks that the objects of a full render only refer to each other, or to objects declared
+// external as kind/name keys, like the registry pull Secret that is created by the deploy job. Pods created
+// outside of the release are declared as Pod/<selector>, with the selector that is expected to select them:
+//   - selectors of Services and PodDisruptionBudgets select pods of a workload in the release, and the
+//     selector of a Deployment selects its own pods
+//   - scaleTargetRefs, claimNames, serviceAccountNames, Secret and ConfigMap references, Ingress backends and
+//     TLS secrets, RoleBinding roles and ServiceAccount subjects, and the authenticationRefs of KEDA triggers
+//     and secretTargetRefs of TriggerAuthentications name an object of the release
+//
+// NetworkPolicy selectors are not checked, a policy may select the pods of other releases on purpose.
+func referenceProblems(objects *renderedObjects, external ...string) []string {
+	c := &referenceChecker{objects: objects, external: make(map[string]bool)}
+	for _, key := range external {
+		c.external[key] = true
+	}
+
+	for _, obj := range objects.objects {
+		if pod, ok := podTemplateOf(obj); ok {
+			c.pods = append(c.pods, pod)
+		}
+	}
+	for _, pod := range c.pods {
+		c.checkPodSpec(pod)
+	}
+	for _, obj := range objects.objects {
+		c.checkObject(obj)
+	}
+	return c.problems
+}
+
+func podTemplateOf(obj runtime.Object) (podTemplate, bool) {
+	key := objectKey(obj)
+	switch o := obj.(type) {
+	case *appsV1.Deployment:
+		return podTemplate{owner: key, labels: o.Spec.Template.Labels, spec: o.Spec.Template.Spec}, true
+	case *batchV1.Job:
+		return podTemplate{owner: key, labels: o.Spec.Template.Labels, spec: o.Spec.Template.Spec}, true
+	case *batchV1.CronJob:
+		template := o.Spec.JobTemplate.Spec.Template
+		return podTemplate{owner: key, labels: template.Labels, spec: template.Spec}, true
+	case *batchV1beta1.CronJob:
+		template := o.Spec.JobTemplate.Spec.Template
+		return podTemplate{owner: key, labels: template.Labels, spec: template.Spec}, true
+	case *coreV1.Pod:
+		return podTemplate{owner: key, labels: o.Labels, spec: o.Spec}, true
+	}
+	return podTemplate{}, false
+}
+
+func (c *referenceChecker) checkObject(obj runtime.Object) {
+	key := objectKey(obj)
+	switch o := obj.(type) {
+	case *appsV1.Deployment:
+		c.requireSelectsOwnPods(key, o.Spec.Selector, o.Spec.Template.Labels)
+	case *coreV1.Service:
+		if len(o.Spec.Selector) > 0 {
+			c.requireSelectsPods(key, "spec.selector", labels.SelectorFromSet(o.Spec.Selector))
+		}
+	case *policyV1.PodDisruptionBudget:
+		c.requireLabelSelectorSelectsPods(key, o.Spec.Selector)
+	case *policyV1beta1.PodDisruptionBudget:
+		c.requireLabelSelectorSelectsPods(key, o.Spec.Selector)
+	case *autoscalingV1.HorizontalPodAutoscaler:
+		c.requireObject(key, "spec.scaleTargetRef", o.Spec.ScaleTargetRef.Kind, o.Spec.ScaleTargetRef.Name)
+	case *autoscalingV2.HorizontalPodAutoscaler:
+		c.requireObject(key, "spec.scaleTargetRef", o.Spec.ScaleTargetRef.Kind, o.Spec.ScaleTargetRef.Name)
+	case *autoscalingV2beta2.HorizontalPodAutoscaler:
+		c.requireObject(key, "spec.scaleTargetRef", o.Spec.ScaleTargetRef.Kind, o.Spec.ScaleTargetRef.Name)
+	case *netV1.Ingress:
+		if o.Spec.DefaultBackend != nil && o.Spec.DefaultBackend.Service != nil {
+			c.requireObject(key, "spec.defaultBackend", "Service", o.Spec.DefaultBackend.Service.Name)
+		}
+		for _, rule := range o.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service != nil {
+					c.requireObject(key, fmt.Sprintf("backend of %s%s", rule.Host, path.Path), "Service", path.Backend.Service.Name)
+				}
+			}
+		}
+		for _, tls := range o.Spec.TLS {
+			// without a secretName the ingress controller serves its default certificate
+			if tls.SecretName != "" {
+				c.requireObject(key, "spec.tls.secretName", "Secret", tls.SecretName)
+			}
+		}
+	case *netV1beta1.Ingress:
+		for _, rule := range o.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				c.requireObject(key, fmt.Sprintf("backend of %s%s", rule.Host, path.Path), "Service", path.Backend.ServiceName)
+			}
+		}
+		for _, tls := range o.Spec.TLS {
+			// without a secretName the ingress controller serves its default certificate
+			if tls.SecretName != "" {
+				c.requireObject(key, "spec.tls.secretName", "Secret", tls.SecretName)
+			}
+		}
+	case *extensionsV1beta1.Ingress:
+		for _, rule := range o.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				c.requireObject(key, fmt.Sprintf("backend of %s%s", rule.Host, path.Path), "Service", path.Backend.ServiceName)
+			}
+		}
+		for _, tls := range o.Spec.TLS {
+			// without a secretName the ingress controller serves its default certificate
+			if tls.SecretName != "" {
+				c.requireObject(key, "spec.tls.secretName", "Secret", tls.SecretName)
+			}
+		}
+	case *unstructured.Unstructured:
+		c.checkCustomResource(key, o)
+	case *rbacV1.RoleBinding:
+		c.requireObject(key, "roleRef", o.RoleRef.Kind, o.RoleRef.Name)
+		for _, subject := range o.Subjects {
+			if subject.Kind == rbacV1.ServiceAccountKind {
+				c.requireObject(key, "subjects", subject.Kind, subject.Name)
+			}
+		}
+	}
+}
+
+// checkCustomResource checks the references of the KEDA objects of the workers.
+func (c *referenceChecker) checkCustomResource(key string, o *unstructured.Unstructured) {
+	switch o.GetKind() {
+	case "ScaledObject":
+		kind, _, _ := unstructured.NestedString(o.Object, "spec", "scaleTargetRef", "kind")
+		if kind == "" {
+			kind = "Deployment"
+		}
+		name, _, _ := unstructured.NestedString(o.Object, "spec", "scaleTargetRef", "name")
+		c.requireObject(key, "spec.scaleTargetRef", kind, name)
+		triggers, _, _ := unstructured.NestedSlice(o.Object, "spec", "triggers")
+		for _, trigger := range triggers {
+			trigger, _ := trigger.(map[string]interface{})
+			if name, ok, _ := unstructured.NestedString(trigger, "authenticationRef", "name"); ok {
+				c.requireObject(key, "spec.triggers.authenticationRef", "TriggerAuthentication", name)
+			}
+		}
+	case "TriggerAuthentication":
+		refs, _, _ := unstructured.NestedSlice(o.Object, "spec", "secretTargetRef")
+		for _, ref := range refs {
+			ref, _ := ref.(map[string]interface{})
+			name, _, _ := unstructured.NestedString(ref, "name")
+			c.requireObject(key, "spec.secretTargetRef", "Secret", name)
+		}
+	}
+}
+
+func (c *referenceChecker) checkPodSpec(pod podTemplate) {
+	// every namespace has a default ServiceAccount
+	if name := pod.spec.ServiceAccountName; name != "" && name != "default" {
+		c.requireObject(pod.owner, "serviceAccountName", "ServiceAccount", name)
+	}
+	for _, secret := range pod.spec.ImagePullSecrets {
+		c.requireObject(pod.owner, "imagePullSecrets", "Secret", secret.Name)
+	}
+	for _, volume := range pod.spec.Volumes {
+		field := fmt.Sprintf("volume %q", volume.Name)
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			c.requireObject(pod.owner, field, "PersistentVolumeClaim", volume.PersistentVolumeClaim.ClaimName)
+		case volume.Secret != nil && !isOptional(volume.Secret.Optional):
+			c.requireObject(pod.owner, field, "Secret", volume.Secret.SecretName)
+		case volume.ConfigMap != nil && !isOptional(volume.ConfigMap.Optional):
+			c.requireObject(pod.owner, field, "ConfigMap", volume.ConfigMap.Name)
+		}
+	}
+
+	containers := append(append([]coreV1.Container{}, pod.spec.InitContainers...), pod.spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			field := fmt.Sprintf("container %q envFrom", container.Name)
+			if envFrom.SecretRef != nil && !isOptional(envFrom.SecretRef.Optional) {
+				c.requireObject(pod.owner, field, "Secret", envFrom.SecretRef.Name)
+			}
+			if envFrom.ConfigMapRef != nil && !isOptional(envFrom.ConfigMapRef.Optional) {
+				c.requireObject(pod.owner, field, "ConfigMap", envFrom.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			field := fmt.Sprintf("container %q env %s", container.Name, env.Name)
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil && !isOptional(ref.Optional) {
+				c.requireObject(pod.owner, field, "Secret", ref.Name)
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && !isOptional(ref.Optional) {
+				c.requireObject(pod.owner, field, "ConfigMap", ref.Name)
+			}
+		}
+	}
+}
+
+func (c *referenceChecker) requireObject(from string, field string, kind string, name string) {
+	key := kind + "/" + name
+	if _, ok := c.objects.byKey[key]; ok || c.external[key] {
+		return
+	}
+	c.problems = append(c.problems, fmt.Sprintf("%s %s: %s is not rendered and not declared external", from, field, key))
+}
+
+func (c *referenceChecker) requireLabelSelectorSelectsPods(from string, labelSelector *metav1.LabelSelector) {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		c.problems = append(c.problems, fmt.Sprintf("%s spec.selector: %s", from, err))
+		return
+	}
+	c.requireSelectsPods(from, "spec.selector", selector)
+}
+
+func (c *referenceChecker) requireSelectsPods(from string, field string, selector labels.Selector) {
+	if c.external["Pod/"+selector.String()] {
+		return
+	}
+	for _, pod := range c.pods {
+		if selector.Matches(labels.Set(pod.labels)) {
+			return
+		}
+	}
+	c.problems = append(c.problems, fmt.Sprintf("%s %s: %q selects no pods of the release", from, field, selector))
+}
+
+func (c *referenceChecker) requireSelectsOwnPods(from string, labelSelector *metav1.LabelSelector, podLabels map[string]string) {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		c.problems = append(c.problems, fmt.Sprintf("%s spec.selector: %s", from, err))
+		return
+	}
+	if !selector.Matches(labels.Set(podLabels)) {
+		c.problems = append(c.problems, fmt.Sprintf("%s spec.selector: %q doesn't select the pods of its own template", from, selector))
+	}
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
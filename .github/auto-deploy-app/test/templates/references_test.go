@@ -0,0 +1,292 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"github.com/stretchr/testify/require"
+)
+
+// externalObjects are the objects the testdata values refer to that are not part of the release: Secrets
+// created by the deploy job, cert-manager and Crossplane, and Secrets and ConfigMaps of the application.
+var externalObjects = []string{
+	"Secret/gitlab-registry",
+	"Secret/mailer-registry",
+	"Secret/web-full-secret",
+	"Secret/workers-secret",
+	"Secret/db-initialize-secret",
+	"Secret/production-extra",
+	"Secret/production-auto-deploy-tls",
+	"Secret/app-postgres",
+	"Secret/mysecret",
+	"ConfigMap/test-config",
+	"ConfigMap/mailer-config",
+	"ConfigMap/report-config",
+}
+
+func TestReferences_Tracks(t *testing.T) {
+	valuesFiles, err := filepath.Glob(filepath.Join(testdataPath, "*.yaml"))
+	require.NoError(t, err)
+
+	variants := []struct {
+		track           string
+		releaseOverride string
+	}{
+		{track: "stable"},
+		{track: "canary"},
+		{track: "rollout"},
+		{track: "stable", releaseOverride: "other-app"},
+		{track: "canary", releaseOverride: "other-app"},
+	}
+
+	for _, valuesFile := range valuesFiles {
+		for _, variant := range variants {
+			name := filepath.Base(valuesFile) + "/" + variant.track
+			if variant.releaseOverride != "" {
+				name += " with releaseOverride"
+			}
+			t.Run(name, func(t *testing.T) {
+				values := map[string]string{
+					"application.track": variant.track,
+					// enable the objects that refer to the Deployment
+					"hpa.enabled":                 "true",
+					"resources.requests.cpu":      "100m",
+					"podDisruptionBudget.enabled": "true",
+				}
+				if variant.releaseOverride != "" {
+					values["releaseOverride"] = variant.releaseOverride
+				}
+				opts := &helm.Options{
+					ValuesFiles: []string{valuesFile},
+					SetValues:   values,
+				}
+				output := mustRenderTemplate(t, opts, "production", nil, nil)
+
+				external := append([]string{}, externalObjects...)
+				if filepath.Base(valuesFile) == "db-initialize.yaml" {
+					// the release that initializes the database has no Deployment yet, the next deploy adds it and
+					// the pods the Service, the PodDisruptionBudget and the HorizontalPodAutoscaler refer to
+					app := "production"
+					if variant.releaseOverride != "" {
+						app = variant.releaseOverride
+					}
+					deployment := app
+					if variant.track != "stable" {
+						deployment += "-" + variant.track
+					}
+					external = append(external,
+						"Pod/app="+app+",tier=web,track="+variant.track,
+						"Pod/app="+app+",release=production,tier=web,track="+variant.track,
+						"Deployment/"+deployment)
+				}
+				mustResolveReferences(t, mustDecodeObjects(t, output), external...)
+			})
+		}
+	}
+}
+
+func TestReferenceProblems(t *testing.T) {
+	tcs := []struct {
+		name     string
+		output   string
+		external []string
+
+		expectedProblems []string
+	}{
+		{
+			name: "resolved references",
+			output: `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      imagePullSecrets:
+      - name: registry
+      volumes:
+      - name: data
+        persistentVolumeClaim:
+          claimName: data
+      containers:
+      - name: web
+        envFrom:
+        - secretRef:
+            name: optional
+            optional: true
+---
+apiVersion: autoscaling/v1
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  maxReplicas: 2
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+`,
+			external: []string{"Secret/registry"},
+		},
+		{
+			name: "dangling references",
+			output: `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web-canary
+spec:
+  selector:
+    matchLabels:
+      app: web
+      track: stable
+  template:
+    metadata:
+      labels:
+        app: web
+        track: canary
+    spec:
+      serviceAccountName: web
+      volumes:
+      - name: data
+        persistentVolumeClaim:
+          claimName: data
+      containers:
+      - name: web
+        env:
+        - name: PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: db
+              key: password
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: worker
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+      track: stable
+---
+apiVersion: autoscaling/v1
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  maxReplicas: 2
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  tls:
+  - hosts:
+    - example.com
+    secretName: web-tls
+  rules:
+  - host: example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web-app
+            port:
+              number: 5000
+---
+apiVersion: keda.sh/v1alpha1
+kind: ScaledObject
+metadata:
+  name: worker
+spec:
+  scaleTargetRef:
+    name: worker
+  triggers:
+  - type: redis
+    authenticationRef:
+      name: redis
+---
+apiVersion: keda.sh/v1alpha1
+kind: TriggerAuthentication
+metadata:
+  name: worker
+spec:
+  secretTargetRef:
+  - parameter: password
+    name: redis
+    key: password
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: reader
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: reader
+subjects:
+- kind: ServiceAccount
+  name: web
+`,
+			external: []string{"Secret/web-tls"},
+			expectedProblems: []string{
+				`Deployment/web-canary serviceAccountName: ServiceAccount/web is not rendered and not declared external`,
+				`Deployment/web-canary volume "data": PersistentVolumeClaim/data is not rendered and not declared external`,
+				`Deployment/web-canary container "web" env PASSWORD: Secret/db is not rendered and not declared external`,
+				`Deployment/web-canary spec.selector: "app=web,track=stable" doesn't select the pods of its own template`,
+				`Service/web spec.selector: "app=worker" selects no pods of the release`,
+				`PodDisruptionBudget/web spec.selector: "app=web,track=stable" selects no pods of the release`,
+				`HorizontalPodAutoscaler/web spec.scaleTargetRef: Deployment/web is not rendered and not declared external`,
+				`Ingress/web backend of example.com/: Service/web-app is not rendered and not declared external`,
+				`ScaledObject/worker spec.scaleTargetRef: Deployment/worker is not rendered and not declared external`,
+				`ScaledObject/worker spec.triggers.authenticationRef: TriggerAuthentication/redis is not rendered and not declared external`,
+				`TriggerAuthentication/worker spec.secretTargetRef: Secret/redis is not rendered and not declared external`,
+				`RoleBinding/reader roleRef: Role/reader is not rendered and not declared external`,
+				`RoleBinding/reader subjects: ServiceAccount/web is not rendered and not declared external`,
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedProblems, referenceProblems(mustDecodeObjects(t, tc.output), tc.external...))
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// The chart is loaded from disk once per test binary, every render only merges the values and executes
+// the templates. The loaded chart is shared, so templates must not be rendered concurrently.
+var (
+	loadChartOnce sync.Once
+	loadedChart   *chart.Chart
+	loadChartErr  error
+)
+
+// sourceRegexp matches the header helm prints above every rendered template, capturing the template path
+var sourceRegexp = regexp.MustCompile("# Source: [^/]+/(.+)")
+
+// renderOptions are the `helm template` flags the tests may pass as extra helm arguments.
+type renderOptions struct {
+	namespace   string
+	kubeVersion string
+	apiVersions []string
+}
+
+func loadChart() (*chart.Chart, error) {
+	loadChartOnce.Do(func() {
+		loadedChart, loadChartErr = loader.Load(helmChartPath)
+	})
+	return loadedChart, loadChartErr
+}
+
+// renderTemplate renders the chart of the working tree, see renderChart.
+func renderTemplate(opts *helm.Options, releaseName string, templates []string, extraHelmArgs []string) (string, error) {
+	ch, err := loadChart()
+	if err != nil {
+		return "", fmt.Errorf("failed to load chart: %w", err)
+	}
+	return renderChart(ch, opts, releaseName, templates, extraHelmArgs)
+}
+
+// renderChart renders a chart in-process the way `helm template` does: the templates are rendered against
+// the default capabilities, manifests are sorted in install order and followed by the hooks, and with
+// templates only the documents of those templates are returned.
+func renderChart(ch *chart.Chart, opts *helm.Options, releaseName string, templates []string, extraHelmArgs []string) (string, error) {
+	renderOpts, err := parseRenderArgs(opts, extraHelmArgs)
+	if err != nil {
+		return "", err
+	}
+	if err := chartutil.ValidateReleaseName(releaseName); err != nil {
+		return "", fmt.Errorf("release name %q: %w", releaseName, err)
+	}
+
+	valueOpts := &values.Options{ValueFiles: opts.ValuesFiles}
+	for _, key := range sortedKeys(opts.SetValues) {
+		valueOpts.Values = append(valueOpts.Values, key+"="+opts.SetValues[key])
+	}
+	for _, key := range sortedKeys(opts.SetStrValues) {
+		valueOpts.StringValues = append(valueOpts.StringValues, key+"="+opts.SetStrValues[key])
+	}
+	for _, key := range sortedKeys(opts.SetFiles) {
+		valueOpts.FileValues = append(valueOpts.FileValues, key+"="+opts.SetFiles[key])
+	}
+	vals, err := valueOpts.MergeValues(getter.Providers{})
+	if err != nil {
+		return "", err
+	}
+
+	caps := chartutil.DefaultCapabilities.Copy()
+	if renderOpts.kubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(renderOpts.kubeVersion)
+		if err != nil {
+			return "", fmt.Errorf("invalid kube version '%s': %w", renderOpts.kubeVersion, err)
+		}
+		caps.KubeVersion = *kubeVersion
+	}
+	caps.APIVersions = append(caps.APIVersions, renderOpts.apiVersions...)
+
+	releaseOpts := chartutil.ReleaseOptions{Name: releaseName, Namespace: renderOpts.namespace, Revision: 1, IsInstall: true}
+	valuesToRender, err := chartutil.ToRenderValues(ch, vals, releaseOpts, caps)
+	if err != nil {
+		return "", err
+	}
+	files, err := engine.Render(ch, valuesToRender)
+	if *templateCoverageFlag && ch == loadedChart {
+		if err := chartCoverage.record(ch, valuesToRender, files, err); err != nil {
+			return "", err
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	for name := range files {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			delete(files, name)
+		}
+	}
+	hooks, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered strings.Builder
+	for _, m := range manifests {
+		fmt.Fprintf(&rendered, "---\n# Source: %s\n%s\n", m.Name, m.Content)
+	}
+	output := strings.TrimSpace(rendered.String()) + "\n"
+	for _, h := range hooks {
+		output += fmt.Sprintf("---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+
+	if len(templates) > 0 {
+		output, err = showOnly(output, templates)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// showOnly keeps the documents rendered from the given templates, like `helm template --show-only`.
+func showOnly(output string, templates []string) (string, error) {
+	split := releaseutil.SplitManifests(output)
+	keys := make([]string, 0, len(split))
+	for key := range split {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var shown strings.Builder
+	for _, template := range templates {
+		missing := true
+		for _, key := range keys {
+			submatch := sourceRegexp.FindStringSubmatch(split[key])
+			if len(submatch) == 0 {
+				continue
+			}
+			if matched, _ := filepath.Match(filepath.ToSlash(template), submatch[1]); !matched {
+				continue
+			}
+			fmt.Fprintf(&shown, "---\n%s\n", split[key])
+			missing = false
+		}
+		if missing {
+			return "", fmt.Errorf("could not find template %s in chart", template)
+		}
+	}
+	return shown.String(), nil
+}
+
+// parseRenderArgs reads the namespace from the helm options and the `helm template` flags from the extra
+// helm arguments. Flags that the in-process rendering doesn't implement are rejected.
+func parseRenderArgs(opts *helm.Options, extraHelmArgs []string) (renderOptions, error) {
+	renderOpts := renderOptions{namespace: "default"}
+	if opts.KubectlOptions != nil && opts.KubectlOptions.Namespace != "" {
+		renderOpts.namespace = opts.KubectlOptions.Namespace
+	}
+
+	for i := 0; i < len(extraHelmArgs); i++ {
+		flag, value, hasValue := strings.Cut(extraHelmArgs[i], "=")
+		if !hasValue {
+			if i+1 == len(extraHelmArgs) {
+				return renderOpts, fmt.Errorf("helm argument %s needs a value", flag)
+			}
+			i++
+			value = extraHelmArgs[i]
+		}
+
+		switch flag {
+		case "--api-versions", "-a":
+			renderOpts.apiVersions = append(renderOpts.apiVersions, strings.Split(value, ",")...)
+		case "--kube-version":
+			renderOpts.kubeVersion = value
+		case "--namespace", "-n":
+			renderOpts.namespace = value
+		default:
+			return renderOpts, fmt.Errorf("helm argument %s is not supported by the in-process rendering", flag)
+		}
+	}
+	return renderOpts, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
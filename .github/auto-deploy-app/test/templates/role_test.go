@@ -10,7 +10,6 @@ import (
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/require"
 	rbacV1 "k8s.io/api/rbac/v1"
-	coreV1 "k8s.io/api/core/v1"
 )
 
 func TestRoleTemplate(t *testing.T) {
@@ -34,7 +33,7 @@ func TestRoleTemplate(t *testing.T) {
 			CaseName: "not created by default",
 			Values:   map[string]string{},
 			ExpectedErrorRegexp: regexp.MustCompile(
-				"Error: could not find template templates/role.yaml in chart",
+				"could not find template templates/role.yaml in chart",
 			),
 		},
 		{
@@ -209,14 +208,12 @@ func TestRoleTemplate(t *testing.T) {
 				return
 			}
 
-			var list coreV1.List
-			helm.UnmarshalK8SYaml(t, output, &list)
+			roles := mustGetAll[*rbacV1.Role](t, mustDecodeObjects(t, output))
 
-			require.Equal(t, len(tc.ExpectedRoles), len(list.Items))
+			require.Equal(t, len(tc.ExpectedRoles), len(roles))
 
 			for i, expectedRole := range tc.ExpectedRoles {
-				var role rbacV1.Role
-				helm.UnmarshalK8SYaml(t, string(list.Items[i].Raw), &role)
+				role := roles[i]
 
 				require.Equal(t, expectedRole.Name, role.Name)
 				require.Equal(t, expectedRole.Rules, role.Rules)
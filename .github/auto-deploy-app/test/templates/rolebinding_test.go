@@ -10,7 +10,6 @@ import (
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/require"
 	rbacV1 "k8s.io/api/rbac/v1"
-	coreV1 "k8s.io/api/core/v1"
 )
 
 func TestRoleBindingTemplate(t *testing.T) {
@@ -35,7 +34,7 @@ func TestRoleBindingTemplate(t *testing.T) {
 			CaseName: "not created by default",
 			Values:   map[string]string{},
 			ExpectedErrorRegexp: regexp.MustCompile(
-				"Error: could not find template templates/rolebinding.yaml in chart",
+				"could not find template templates/rolebinding.yaml in chart",
 			),
 		},
 		{
@@ -235,14 +234,12 @@ func TestRoleBindingTemplate(t *testing.T) {
 				return
 			}
 
-			var list coreV1.List
-			helm.UnmarshalK8SYaml(t, output, &list)
+			roleBindings := mustGetAll[*rbacV1.RoleBinding](t, mustDecodeObjects(t, output))
 
-			require.Equal(t, len(tc.ExpectedRoleBindings), len(list.Items))
+			require.Equal(t, len(tc.ExpectedRoleBindings), len(roleBindings))
 
 			for i, expectedRoleBinding := range tc.ExpectedRoleBindings {
-				var roleBinding rbacV1.RoleBinding
-				helm.UnmarshalK8SYaml(t, string(list.Items[i].Raw), &roleBinding)
+				roleBinding := roleBindings[i]
 
 				require.Equal(t, expectedRoleBinding.Name, roleBinding.Name)
 				require.Equal(t, expectedRoleBinding.RoleRef, roleBinding.RoleRef)
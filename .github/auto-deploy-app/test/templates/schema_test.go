@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValuesSchema_ValuesFiles(t *testing.T) {
+	schema := mustLoadValuesSchema(t)
+
+	valuesFiles, err := filepath.Glob(filepath.Join(testdataPath, "*.yaml"))
+	require.NoError(t, err)
+	valuesFiles = append([]string{helmChartPath + "/values.yaml"}, valuesFiles...)
+
+	for _, valuesFile := range valuesFiles {
+		t.Run(filepath.Base(valuesFile), func(t *testing.T) {
+			b, err := os.ReadFile(valuesFile)
+			require.NoError(t, err)
+
+			mustMatchValuesSchema(t, schema, b)
+		})
+	}
+}
+
+// TestValuesSchema_DeployWorkflowValues checks the values the deploy workflows generate with cmd/deploy-values,
+// the files in testdata/deploy-values, against the schema. internal/deployvalues checks that the files are
+// what cmd/deploy-values prints.
+func TestValuesSchema_DeployWorkflowValues(t *testing.T) {
+	schema := mustLoadValuesSchema(t)
+
+	valuesFiles, err := filepath.Glob(filepath.Join(testdataPath, "deploy-values", "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, valuesFiles)
+
+	for _, valuesFile := range valuesFiles {
+		t.Run(filepath.Base(valuesFile), func(t *testing.T) {
+			b, err := os.ReadFile(valuesFile)
+			require.NoError(t, err)
+
+			mustMatchValuesSchema(t, schema, b)
+
+			opts := &helm.Options{ValuesFiles: []string{valuesFile}}
+			mustRenderTemplate(t, opts, "deploy-workflow", nil, nil)
+		})
+	}
+}
+
+func TestValuesSchema_RejectsInvalidValues(t *testing.T) {
+	releaseName := "values-schema-test"
+	tcs := []struct {
+		name   string
+		values map[string]string
+
+		expectedErrorRegexp *regexp.Regexp
+	}{
+		{
+			name:                "misspelled probe key",
+			values:              map[string]string{"livenessProbe.probetype": "httpGet"},
+			expectedErrorRegexp: regexp.MustCompile(`livenessProbe: Additional property probetype is not allowed`),
+		},
+		{
+			name:                "unknown probe type",
+			values:              map[string]string{"readinessProbe.probeType": "grpc"},
+			expectedErrorRegexp: regexp.MustCompile(`readinessProbe\.probeType: readinessProbe\.probeType must be one of the following`),
+		},
+		{
+			name:                "misspelled hpa key",
+			values:              map[string]string{"hpa.minReplica": "2"},
+			expectedErrorRegexp: regexp.MustCompile(`hpa: Additional property minReplica is not allowed`),
+		},
+		{
+			name:                "unknown top-level key",
+			values:              map[string]string{"replicas": "2"},
+			expectedErrorRegexp: regexp.MustCompile(`\(root\): Additional property replicas is not allowed`),
+		},
+		{
+			name:                "port is not a number",
+			values:              map[string]string{"service.externalPort": "http"},
+			expectedErrorRegexp: regexp.MustCompile(`service\.externalPort: Invalid type\. Expected: integer, given: string`),
+		},
+		{
+			name:                "unknown pull policy",
+			values:              map[string]string{"image.pullPolicy": "Sometimes"},
+			expectedErrorRegexp: regexp.MustCompile(`image\.pullPolicy: image\.pullPolicy must be one of the following`),
+		},
+		{
+			name:                "misspelled tls key",
+			values:              map[string]string{"ingress.tls.enable": "false"},
+			expectedErrorRegexp: regexp.MustCompile(`ingress\.tls: Additional property enable is not allowed`),
+		},
+		{
+			name:                "misspelled worker key",
+			values:              map[string]string{"workers.worker1.replicas": "2"},
+			expectedErrorRegexp: regexp.MustCompile(`workers\.worker1: Additional property replicas is not allowed`),
+		},
+		{
+			name: "misspelled worker probe key",
+			values: map[string]string{
+				"workers.worker1.livenessProbe.httpHeader[0].name":  "custom-header",
+				"workers.worker1.livenessProbe.httpHeader[0].value": "awesome",
+			},
+			expectedErrorRegexp: regexp.MustCompile(`workers\.worker1\.livenessProbe: Additional property httpHeader is not allowed`),
+		},
+		{
+			name: "unknown cronjob concurrency policy",
+			values: map[string]string{
+				"cronjobs.job1.schedule":          "*/5 * * * *",
+				"cronjobs.job1.concurrencyPolicy": "Sometimes",
+			},
+			expectedErrorRegexp: regexp.MustCompile(`cronjobs\.job1\.concurrencyPolicy: cronjobs\.job1\.concurrencyPolicy must be one of the following`),
+		},
+		{
+			name: "misspelled cronjob key",
+			values: map[string]string{
+				"cronjobs.job1.schedule":  "*/5 * * * *",
+				"cronjobs.job1.comand[0]": "/bin/true",
+			},
+			expectedErrorRegexp: regexp.MustCompile(`cronjobs\.job1: Additional property comand is not allowed`),
+		},
+		{
+			name: "persistent volume without mount",
+			values: map[string]string{
+				"persistence.volumes[0].name":             "data",
+				"persistence.volumes[0].claim.size":       "1Gi",
+				"persistence.volumes[0].claim.accessMode": "ReadWriteOnce",
+			},
+			expectedErrorRegexp: regexp.MustCompile(`persistence\.volumes\.0: mount is required`),
+		},
+		{
+			name: "unknown persistent volume access mode",
+			values: map[string]string{
+				"persistence.volumes[0].claim.accessMode": "ReadWriteSometimes",
+			},
+			expectedErrorRegexp: regexp.MustCompile(`persistence\.volumes\.0\.claim\.accessMode: persistence\.volumes\.0\.claim\.accessMode must be one of the following`),
+		},
+		{
+			name:                "misspelled role key",
+			values:              map[string]string{"roles.pod-reader.rule[0].verbs[0]": "get"},
+			expectedErrorRegexp: regexp.MustCompile(`roles\.pod-reader: Additional property rule is not allowed`),
+		},
+		{
+			name: "role binding without roleRefName",
+			values: map[string]string{
+				"roleBindings.pod-reader-binding.subjects[0].kind": "ServiceAccount",
+				"roleBindings.pod-reader-binding.subjects[0].name": "default",
+			},
+			expectedErrorRegexp: regexp.MustCompile(`roleBindings\.pod-reader-binding: roleRefName is required`),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &helm.Options{
+				SetValues: tc.values,
+			}
+			mustRenderTemplate(t, opts, releaseName, nil, tc.expectedErrorRegexp)
+		})
+	}
+}
+
+func mustLoadValuesSchema(t *testing.T) *gojsonschema.Schema {
+	t.Helper()
+
+	b, err := os.ReadFile(helmChartPath + "/values.schema.json")
+	require.NoError(t, err)
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(b))
+	require.NoError(t, err)
+
+	return schema
+}
+
+func mustMatchValuesSchema(t *testing.T, schema *gojsonschema.Schema, values []byte) {
+	t.Helper()
+
+	doc := make(map[string]interface{})
+	require.NoError(t, yaml.Unmarshal(values, &doc))
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(doc))
+	require.NoError(t, err)
+	for _, resultErr := range result.Errors() {
+		t.Errorf("values don't match values.schema.json: %s", resultErr)
+	}
+}
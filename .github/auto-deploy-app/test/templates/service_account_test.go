@@ -29,7 +29,7 @@ func TestServiceAccountTemplate(t *testing.T) {
 			CaseName: "not created by default",
 			Values:   map[string]string{},
 			ExpectedErrorRegexp: regexp.MustCompile(
-				"Error: could not find template templates/service-account.yaml in chart",
+				"could not find template templates/service-account.yaml in chart",
 			),	
 			ExpectedLabels: nil,
 		},
@@ -39,7 +39,7 @@ func TestServiceAccountTemplate(t *testing.T) {
 				"serviceAccount.createNew": "false",
 			},
 			ExpectedErrorRegexp: regexp.MustCompile(
-				"Error: could not find template templates/service-account.yaml in chart",
+				"could not find template templates/service-account.yaml in chart",
 			),
 			ExpectedLabels: nil,
 		},
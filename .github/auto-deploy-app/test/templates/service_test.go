@@ -158,12 +158,12 @@ func TestServiceTemplate_Disable(t *testing.T) {
 		{
 			name:                "with service disabled and track stable",
 			values:              map[string]string{"service.enabled": "false", "application.track": "stable"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/service.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/service.yaml in chart"),
 		},
 		{
 			name:                "with service disabled and track non-stable",
 			values:              map[string]string{"service.enabled": "false", "application.track": "non-stable"},
-			expectedErrorRegexp: regexp.MustCompile("Error: could not find template templates/service.yaml in chart"),
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/service.yaml in chart"),
 		},
 	}
 
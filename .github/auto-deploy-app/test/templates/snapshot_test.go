@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSnapshot renders the whole chart for every values file in testdata/ and compares
+// the full manifest stream with the golden file of the same name in testdata/golden/.
+func TestSnapshot(t *testing.T) {
+	releaseName := "snapshot"
+
+	valuesFiles, err := filepath.Glob(filepath.Join(testdataPath, "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, valuesFiles)
+
+	scenarios := make(map[string]bool)
+	for _, valuesFile := range valuesFiles {
+		scenario := strings.TrimSuffix(filepath.Base(valuesFile), ".yaml")
+		scenarios[scenario] = true
+
+		t.Run(scenario, func(t *testing.T) {
+			opts := &helm.Options{
+				ValuesFiles: []string{valuesFile},
+			}
+			output := mustRenderTemplate(t, opts, releaseName, nil, nil)
+
+			mustMatchGolden(t, scenario, output)
+		})
+	}
+
+	if *updateGolden {
+		return
+	}
+
+	goldenFiles, err := os.ReadDir(goldenPath)
+	require.NoError(t, err)
+	for _, goldenFile := range goldenFiles {
+		scenario := strings.TrimSuffix(goldenFile.Name(), ".yaml")
+		require.Truef(t, scenarios[scenario], "golden file %s has no values file in testdata, remove it", goldenFile.Name())
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeStrict(t *testing.T) {
+	tcs := []struct {
+		name   string
+		output string
+
+		expectedErrorRegexp *regexp.Regexp
+	}{
+		{
+			name: "valid objects",
+			output: `---
+# Source: auto-deploy-app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 5000
+---
+# Source: auto-deploy-app/templates/hpa.yaml
+`,
+		},
+		{
+			name: "probe field under the wrong parent",
+			output: `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web
+        livenessProbe:
+          httpGet:
+            path: /
+            port: 5000
+          httpHeaders:
+          - name: custom-header
+            value: awesome
+`,
+			expectedErrorRegexp: regexp.MustCompile(`unknown field "spec\.template\.spec\.containers\[0\]\.livenessProbe\.httpHeaders"`),
+		},
+		{
+			name: "duplicated field",
+			output: `---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: web
+  name: worker
+`,
+			expectedErrorRegexp: regexp.MustCompile(`key "name" already set in map`),
+		},
+		{
+			name: "unknown field in a List item",
+			output: `---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ServiceAccount
+  metadata:
+    name: web
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: Role
+  metadata:
+    name: reader
+  rule: []
+`,
+			expectedErrorRegexp: regexp.MustCompile(`unknown field "rule"`),
+		},
+		{
+			name: "empty List",
+			output: `---
+apiVersion: v1
+kind: List
+items: []
+`,
+			expectedErrorRegexp: regexp.MustCompile(`rendered List has no items`),
+		},
+		{
+			name: "custom resources are skipped",
+			output: `---
+apiVersion: database.crossplane.io/v1alpha1
+kind: PostgreSQLInstance
+metadata:
+  name: db
+spec:
+  anything: goes
+`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeObjects(tc.output)
+
+			if tc.expectedErrorRegexp == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Regexp(t, tc.expectedErrorRegexp, err.Error())
+		})
+	}
+}
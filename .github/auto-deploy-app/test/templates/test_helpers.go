@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"testing"
 	"strings"
@@ -14,16 +18,40 @@ import (
 	"gopkg.in/yaml.v3"
 	appsV1 "k8s.io/api/apps/v1"
 	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	sigsyaml "sigs.k8s.io/yaml"
 )
 
 const (
 	helmChartPath = "../.."
+	testdataPath  = "../testdata"
+	goldenPath    = testdataPath + "/golden"
 )
 
 var chartName string // dynamically initialized
 
+// strictDecoder decodes YAML into the registered k8s.io/api types and rejects unknown and duplicated fields
+var strictDecoder = json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme, json.SerializerOptions{Yaml: true, Strict: true})
+
+// run `go test ./templates -run TestSnapshot -update` to regenerate the golden files after a template change
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden instead of comparing against them")
+
+// run `go test ./templates -run TestUpgradeSafety -upgrade-from <revision>` to check upgrades from another revision
+var upgradeFrom = flag.String("upgrade-from", "", "git revision of the chart to check upgrades from, at its merge base with HEAD, instead of origin/main")
+
+// run `go test -template-coverage` in test/templates to see which branches of the templates the tests take
+var (
+	templateCoverageFlag = flag.Bool("template-coverage", false, "record the branches of the templates taken by the tests and print a coverage report")
+	templateCoverageMin  = flag.Float64("template-coverage-min", 0, "with -template-coverage, fail if a template has less than this percentage of its branches taken")
+)
+
 func init() {
 	// init chartName dynamically because it is annoying to update this value, but it is needed for some expected labels
 	f, err := os.Open(helmChartPath + "/Chart.yaml")
@@ -44,7 +72,7 @@ func init() {
 
 func mustRenderTemplate(t *testing.T, opts *helm.Options, releaseName string, templates []string, expectedErrorRegexp *regexp.Regexp, extraHelmArgs ...string) (string) {
 
-	output, err := helm.RenderTemplateE(t, opts, helmChartPath, releaseName, templates, extraHelmArgs...)
+	output, err := renderTemplate(opts, releaseName, templates, extraHelmArgs)
 	if expectedErrorRegexp != nil {
 		if err == nil {
 			t.Fatalf("Expected error but didn't happen")
@@ -58,26 +86,201 @@ func mustRenderTemplate(t *testing.T, opts *helm.Options, releaseName string, te
 		return ""
 	}
 
-	// yamllint with extra config
-	// check indenting of sequences with the default k8s style
-	// disable trailing-space check, because sometimes we have empty variables and we don't want to use if blocks around every option
-	cmd := exec.Command("yamllint", "-s", "-d", "{extends: default, rules: {line-length: {max: 160}, indentation: {indent-sequences: false}, trailing-spaces: disable}}", "-")
-	cmd.Stdin = strings.NewReader(output + "\n")
-	var out strings.Builder
-	cmd.Stdout = &out
-	err = cmd.Run()
-
-	if err != nil {
-		t.Fatalf("rendered template had yamllint errors: %s", out.String())
+	// the indentation and blank line checks that used to run through yamllint
+	if problems := lintYAML(output); len(problems) > 0 {
+		t.Fatalf("rendered template had lint errors:\n%s", strings.Join(problems, "\n"))
 		return ""
 	}
 
-	// needed, because yamllint does not detect empty lines containing spaces
-	require.NotRegexpf(t, regexp.MustCompile("\n[[:space:]]*\n"), output, "found empty lines in output")
+	// needed, because helm.UnmarshalK8SYaml silently drops fields that don't exist in the target type
+	mustDecodeStrict(t, output)
 
 	return output
 }
 
+// mustDecodeStrict decodes every document of the rendered output into the k8s.io/api type matching its
+// apiVersion and kind, and fails on unknown or duplicated fields.
+func mustDecodeStrict(t *testing.T, output string) {
+	t.Helper()
+
+	_, err := decodeObjects(output)
+	require.NoError(t, err)
+}
+
+// renderedObjects indexes the objects of a rendered output by kind and name. Documents of a `---` stream
+// and items of a List are flattened, so a test doesn't need to know how a template wraps its objects.
+type renderedObjects struct {
+	objects []runtime.Object
+	byKey   map[string]runtime.Object
+}
+
+// mustDecodeObjects decodes the rendered output into an index of typed objects. Kinds that are not built
+// into Kubernetes, like custom resources, are indexed as *unstructured.Unstructured.
+func mustDecodeObjects(t *testing.T, output string) *renderedObjects {
+	t.Helper()
+
+	objects, err := decodeObjects(output)
+	require.NoError(t, err)
+
+	index := &renderedObjects{objects: objects, byKey: make(map[string]runtime.Object)}
+	for _, obj := range objects {
+		key := objectKey(obj)
+		require.NotContainsf(t, index.byKey, key, "%s is rendered more than once", key)
+		index.byKey[key] = obj
+	}
+	return index
+}
+
+// keys returns the kind/name keys of all objects, in render order.
+func (r *renderedObjects) keys() []string {
+	keys := make([]string, 0, len(r.objects))
+	for _, obj := range r.objects {
+		keys = append(keys, objectKey(obj))
+	}
+	return keys
+}
+
+// mustGet returns the rendered object of type T with the given name, e.g.
+// `mustGet[*appsV1.Deployment](t, objects, "production")`.
+func mustGet[T runtime.Object](t *testing.T, r *renderedObjects, name string) T {
+	t.Helper()
+
+	kind := kindOf[T](t)
+	obj, ok := r.byKey[kind+"/"+name]
+	require.Truef(t, ok, "no %s %q rendered, got %v", kind, name, r.keys())
+	typed, ok := obj.(T)
+	require.Truef(t, ok, "%s %q is rendered as %s, not as %T", kind, name, obj.GetObjectKind().GroupVersionKind().GroupVersion(), typed)
+	return typed
+}
+
+// mustGetAll returns all rendered objects of type T, in render order.
+func mustGetAll[T runtime.Object](t *testing.T, r *renderedObjects) []T {
+	t.Helper()
+
+	kind := kindOf[T](t)
+	var all []T
+	for _, obj := range r.objects {
+		if typed, ok := obj.(T); ok {
+			all = append(all, typed)
+			continue
+		}
+		require.NotEqualf(t, kind, obj.GetObjectKind().GroupVersionKind().Kind, "%s is rendered as %s, not as %T",
+			objectKey(obj), obj.GetObjectKind().GroupVersionKind().GroupVersion(), *new(T))
+	}
+	return all
+}
+
+// mustGetCustomResource returns the rendered object of a kind that is not built into Kubernetes.
+func mustGetCustomResource(t *testing.T, r *renderedObjects, kind string, name string) *unstructured.Unstructured {
+	t.Helper()
+
+	obj, ok := r.byKey[kind+"/"+name]
+	require.Truef(t, ok, "no %s %q rendered, got %v", kind, name, r.keys())
+	u, ok := obj.(*unstructured.Unstructured)
+	require.Truef(t, ok, "%s is a built-in kind, use mustGet", kind)
+	return u
+}
+
+func kindOf[T runtime.Object](t *testing.T) string {
+	t.Helper()
+
+	var zero T
+	gvks, _, err := scheme.Scheme.ObjectKinds(reflect.New(reflect.TypeOf(zero).Elem()).Interface().(runtime.Object))
+	require.NoErrorf(t, err, "%T is not a built-in kind, use mustGetCustomResource", zero)
+	return gvks[0].Kind
+}
+
+func objectKey(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return obj.GetObjectKind().GroupVersionKind().Kind
+	}
+	return obj.GetObjectKind().GroupVersionKind().Kind + "/" + accessor.GetName()
+}
+
+// decodeObjects decodes the rendered output strictly, flattening document streams and Lists.
+// Kinds that are not built into Kubernetes are decoded without checking their fields.
+func decodeObjects(output string) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(output)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if isEmptyDocument(doc) {
+			continue
+		}
+		objects, err = appendDecodedObject(objects, doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func appendDecodedObject(objects []runtime.Object, data []byte) ([]runtime.Object, error) {
+	obj, gvk, err := strictDecoder.Decode(data, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		u := new(unstructured.Unstructured)
+		if err := sigsyaml.Unmarshal(data, &u.Object); err != nil {
+			return nil, fmt.Errorf("rendered object doesn't decode: %w\n%s", err, data)
+		}
+		return append(objects, u), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rendered object doesn't decode strictly: %w\n%s", err, data)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(*gvk)
+
+	list, ok := obj.(*coreV1.List)
+	if !ok {
+		return append(objects, obj), nil
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("rendered %s has no items", gvk.Kind)
+	}
+	for _, item := range list.Items {
+		objects, err = appendDecodedObject(objects, item.Raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// isEmptyDocument reports whether a YAML document consists only of comments and whitespace,
+// like the "# Source:" header helm prints for a template that renders nothing.
+func isEmptyDocument(doc []byte) bool {
+	for _, line := range strings.Split(string(doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line != "---" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// mustMatchGolden compares the rendered output of a snapshot scenario with its golden file,
+// or writes the golden file when the tests are run with -update.
+func mustMatchGolden(t *testing.T, scenario string, output string) {
+	t.Helper()
+
+	path := filepath.Join(goldenPath, scenario+".yaml")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(goldenPath, 0755))
+		require.NoError(t, os.WriteFile(path, []byte(output+"\n"), 0644))
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoErrorf(t, err, "no golden file for scenario %q, run the tests with -update to create it", scenario)
+	require.Equalf(t, string(expected), output+"\n", "rendered manifests differ from %s, run the tests with -update if the change is intended", path)
+}
+
 type workerDeploymentTestCase struct {
 	ExpectedName           string
 	ExpectedCmd            []string
@@ -112,16 +315,21 @@ type workerDeploymentHostNetworkTestCase struct {
 	ExpectedHostNetwork bool
 }
 
-type deploymentList struct {
-	metav1.TypeMeta `json:",inline"`
-
-	Items []appsV1.Deployment `json:"items" protobuf:"bytes,2,rep,name=items"`
-}
-
-type deploymentAppsV1List struct {
-	metav1.TypeMeta `json:",inline"`
-
-	Items []appsV1.Deployment `json:"items" protobuf:"bytes,2,rep,name=items"`
+// expectedWorkerLabels returns the labels of a worker Deployment and its pods.
+func expectedWorkerLabels(name, release, component string) map[string]string {
+	return map[string]string{
+		"app":                          name,
+		"chart":                        chartName,
+		"heritage":                     "Helm",
+		"release":                      release,
+		"tier":                         "worker",
+		"track":                        "stable",
+		"app.kubernetes.io/component":  component,
+		"app.kubernetes.io/name":       name,
+		"helm.sh/chart":                chartName,
+		"app.kubernetes.io/managed-by": "Helm",
+		"app.kubernetes.io/instance":   release,
+	}
 }
 
 func mergeStringMap(dst, src map[string]string) {
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// loadChartAtRevision loads the chart as it is committed at a git revision, without touching the working tree.
+func loadChartAtRevision(revision string) (*chart.Chart, error) {
+	out, err := exec.Command("git", "-C", helmChartPath, "rev-parse", "--show-toplevel", "--show-prefix").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the chart in the git repository: %w", err)
+	}
+	// git resolves the tree path against the working directory, so archive from the top level
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("failed to find the chart in the git repository: %q", out)
+	}
+	topLevel, tree := lines[0], revision+":"+strings.TrimSuffix(lines[1], "/")
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", topLevel, "archive", "--format=tar", tree)
+	cmd.Stderr = &stderr
+	archive, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chart at %s: %w: %s", revision, err, stderr.String())
+	}
+
+	var files []*loader.BufferedFile
+	reader := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the tests are not part of the chart, see .helmignore
+		if header.Typeflag != tar.TypeReg || strings.HasPrefix(header.Name, "test/") {
+			continue
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &loader.BufferedFile{Name: header.Name, Data: data})
+	}
+	return loader.LoadFiles(files)
+}
+
+// mergeBase returns the merge base of HEAD and the first of the revisions that exists, the revision of the
+// chart a change is installed on top of.
+func mergeBase(revisions ...string) (string, error) {
+	var errs []string
+	for _, revision := range revisions {
+		var stderr bytes.Buffer
+		cmd := exec.Command("git", "-C", helmChartPath, "merge-base", "HEAD", revision)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v: %s", revision, err, strings.TrimSpace(stderr.String())))
+			continue
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", fmt.Errorf("failed to find the merge base with HEAD: %s", strings.Join(errs, "; "))
+}
+
+// immutableFieldChanges compares the objects of two renders with the same values, the release as it is installed
+// and the release it is upgraded to, and returns the changes of fields that the API server refuses to update.
+// Such a change makes `helm upgrade` fail, the object has to be deleted and recreated instead.
+//
+// Hooks are left out, helm deletes and recreates them on every upgrade instead of patching them.
+func immutableFieldChanges(installed *renderedObjects, upgraded *renderedObjects) []string {
+	var changes []string
+	changed := func(key string, field string, from interface{}, to interface{}) {
+		changes = append(changes, fmt.Sprintf("%s %s: immutable field changed from %v to %v", key, field, from, to))
+	}
+
+	for _, obj := range upgraded.objects {
+		key := objectKey(obj)
+		previous, ok := installed.byKey[key]
+		if !ok {
+			continue
+		}
+
+		switch o := obj.(type) {
+		case *appsV1.Deployment:
+			p, ok := previous.(*appsV1.Deployment)
+			if ok && !equality.Semantic.DeepEqual(p.Spec.Selector, o.Spec.Selector) {
+				changed(key, "spec.selector", formatSelector(p.Spec.Selector), formatSelector(o.Spec.Selector))
+			}
+		case *batchV1.Job:
+			p, ok := previous.(*batchV1.Job)
+			if !ok || isHook(p.Annotations) || isHook(o.Annotations) {
+				continue
+			}
+			if !equality.Semantic.DeepEqual(p.Spec.Selector, o.Spec.Selector) {
+				changed(key, "spec.selector", formatSelector(p.Spec.Selector), formatSelector(o.Spec.Selector))
+			}
+			if !equality.Semantic.DeepEqual(p.Spec.Template, o.Spec.Template) {
+				changes = append(changes, fmt.Sprintf("%s spec.template: immutable field changed", key))
+			}
+		case *coreV1.PersistentVolumeClaim:
+			p, ok := previous.(*coreV1.PersistentVolumeClaim)
+			if !ok {
+				continue
+			}
+			if !equality.Semantic.DeepEqual(p.Spec.AccessModes, o.Spec.AccessModes) {
+				changed(key, "spec.accessModes", p.Spec.AccessModes, o.Spec.AccessModes)
+			}
+			if from, to := stringValue(p.Spec.StorageClassName), stringValue(o.Spec.StorageClassName); from != to {
+				changed(key, "spec.storageClassName", from, to)
+			}
+			if p.Spec.VolumeName != o.Spec.VolumeName {
+				changed(key, "spec.volumeName", p.Spec.VolumeName, o.Spec.VolumeName)
+			}
+			if !equality.Semantic.DeepEqual(p.Spec.Selector, o.Spec.Selector) {
+				changed(key, "spec.selector", formatSelector(p.Spec.Selector), formatSelector(o.Spec.Selector))
+			}
+			// a claim can only grow, and only if its StorageClass allows volume expansion
+			from, to := p.Spec.Resources.Requests[coreV1.ResourceStorage], o.Spec.Resources.Requests[coreV1.ResourceStorage]
+			if to.Cmp(from) < 0 {
+				changes = append(changes, fmt.Sprintf("%s spec.resources.requests.storage: shrinks from %s to %s", key, from.String(), to.String()))
+			}
+		case *coreV1.Service:
+			p, ok := previous.(*coreV1.Service)
+			if !ok {
+				continue
+			}
+			if p.Spec.ClusterIP != o.Spec.ClusterIP {
+				changed(key, "spec.clusterIP", quoted(p.Spec.ClusterIP), quoted(o.Spec.ClusterIP))
+			}
+			// the cluster IP allocated to a Service is kept by a patch, but an ExternalName Service must not have one
+			if p.Spec.Type != o.Spec.Type && (p.Spec.Type == coreV1.ServiceTypeExternalName || o.Spec.Type == coreV1.ServiceTypeExternalName) {
+				changed(key, "spec.type", p.Spec.Type, o.Spec.Type)
+			}
+		case *rbacV1.RoleBinding:
+			p, ok := previous.(*rbacV1.RoleBinding)
+			if ok && p.RoleRef != o.RoleRef {
+				changed(key, "roleRef", p.RoleRef.Kind+"/"+p.RoleRef.Name, o.RoleRef.Kind+"/"+o.RoleRef.Name)
+			}
+		}
+	}
+	return changes
+}
+
+func isHook(annotations map[string]string) bool {
+	_, ok := annotations["helm.sh/hook"]
+	return ok
+}
+
+func formatSelector(selector *metav1.LabelSelector) string {
+	return quoted(metav1.FormatLabelSelector(selector))
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func quoted(s string) string {
+	return fmt.Sprintf("%q", s)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpgradeSafety renders every values file in testdata/ like TestSnapshot and checks that upgrading the
+// installed release to it doesn't change immutable fields. The installed release is the render of the chart at
+// the merge base of HEAD and origin/main, or main, or of the git revision of -upgrade-from. The golden files
+// are no baseline, -update rewrites them in the same change.
+func TestUpgradeSafety(t *testing.T) {
+	if err := exec.Command("git", "-C", helmChartPath, "rev-parse", "HEAD").Run(); err != nil {
+		t.Skip("the chart is not in a git repository")
+	}
+	releaseName := "snapshot"
+
+	revisions := []string{"origin/main", "main"}
+	if *upgradeFrom != "" {
+		revisions = []string{*upgradeFrom}
+	}
+	base, err := mergeBase(revisions...)
+	if err != nil && *upgradeFrom == "" {
+		t.Skipf("no installed chart to upgrade from, fetch origin/main or pass -upgrade-from: %s", err)
+	}
+	require.NoError(t, err)
+	installedChart, err := loadChartAtRevision(base)
+	require.NoError(t, err)
+
+	valuesFiles, err := filepath.Glob(filepath.Join(testdataPath, "*.yaml"))
+	require.NoError(t, err)
+
+	for _, valuesFile := range valuesFiles {
+		scenario := strings.TrimSuffix(filepath.Base(valuesFile), ".yaml")
+
+		t.Run(scenario, func(t *testing.T) {
+			opts := &helm.Options{
+				ValuesFiles: []string{valuesFile},
+			}
+
+			installed, err := renderChart(installedChart, opts, releaseName, nil, nil)
+			if err != nil {
+				t.Skipf("the chart at %s doesn't render the scenario: %s", base, err)
+			}
+			upgraded := mustRenderTemplate(t, opts, releaseName, nil, nil)
+
+			for _, change := range immutableFieldChanges(mustDecodeObjects(t, installed), mustDecodeObjects(t, upgraded)) {
+				t.Errorf("upgrading from %s fails: %s", base, change)
+			}
+		})
+	}
+}
+
+func TestLoadChartAtRevision(t *testing.T) {
+	if err := exec.Command("git", "-C", helmChartPath, "rev-parse", "HEAD").Run(); err != nil {
+		t.Skip("the chart is not in a git repository")
+	}
+
+	ch, err := loadChartAtRevision("HEAD")
+	require.NoError(t, err)
+	require.Equal(t, "auto-deploy-app", ch.Name())
+	for _, file := range ch.Files {
+		require.Falsef(t, strings.HasPrefix(file.Name, "test/"), "%s is loaded into the chart", file.Name)
+	}
+
+	output, err := renderChart(ch, &helm.Options{}, "revision", nil, nil)
+	require.NoError(t, err)
+	require.Contains(t, output, "kind: Deployment")
+}
+
+func TestImmutableFieldChanges(t *testing.T) {
+	installed := `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ClusterIP
+  clusterIP: None
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  accessModes:
+  - ReadWriteOnce
+  storageClassName: fast
+  resources:
+    requests:
+      storage: 10Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: import
+spec:
+  template:
+    spec:
+      containers:
+      - name: import
+        image: app:1
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-upgrade
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: app:1
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: reader
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: reader
+`
+
+	tcs := []struct {
+		name     string
+		upgraded string
+
+		expectedChanges []string
+	}{
+		{
+			name:     "same release",
+			upgraded: installed,
+		},
+		{
+			name: "mutable fields and new objects",
+			upgraded: `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  labels:
+    track: stable
+spec:
+  type: ClusterIP
+  clusterIP: None
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  accessModes:
+  - ReadWriteOnce
+  storageClassName: fast
+  resources:
+    requests:
+      storage: 20Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+        version: "2"
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-upgrade
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: app:2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+spec:
+  selector:
+    matchLabels:
+      app: worker
+  template:
+    metadata:
+      labels:
+        app: worker
+`,
+		},
+		{
+			name: "immutable fields",
+			upgraded: `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ExternalName
+  externalName: web.example.com
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  accessModes:
+  - ReadWriteMany
+  resources:
+    requests:
+      storage: 5Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+      track: stable
+  template:
+    metadata:
+      labels:
+        app: web
+        track: stable
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: import
+spec:
+  template:
+    spec:
+      containers:
+      - name: import
+        image: app:2
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: reader
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: view
+`,
+			expectedChanges: []string{
+				`Service/web spec.clusterIP: immutable field changed from "None" to ""`,
+				`Service/web spec.type: immutable field changed from ClusterIP to ExternalName`,
+				`PersistentVolumeClaim/data spec.accessModes: immutable field changed from [ReadWriteOnce] to [ReadWriteMany]`,
+				`PersistentVolumeClaim/data spec.storageClassName: immutable field changed from fast to `,
+				`PersistentVolumeClaim/data spec.resources.requests.storage: shrinks from 10Gi to 5Gi`,
+				`Deployment/web spec.selector: immutable field changed from "app=web" to "app=web,track=stable"`,
+				`Job/import spec.template: immutable field changed`,
+				`RoleBinding/reader roleRef: immutable field changed from Role/reader to ClusterRole/view`,
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := immutableFieldChanges(mustDecodeObjects(t, installed), mustDecodeObjects(t, tc.upgraded))
+			require.Equal(t, tc.expectedChanges, changes)
+		})
+	}
+}
@@ -57,7 +57,7 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 			CaseName: "long release name",
 			Release:  strings.Repeat("r", 80),
 
-			ExpectedErrorRegexp: regexp.MustCompile("Error: release name .* length must not be longer than 53"),
+			ExpectedErrorRegexp: regexp.MustCompile("release name .* length must not be longer than 53"),
 		},
 		{
 			CaseName: "strategyType",
@@ -211,12 +211,11 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 				return
             }
 
-			var deployments deploymentList
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 
 				require.Equal(t, expectedDeployment.ExpectedName, deployment.Name)
 				require.Equal(t, expectedDeployment.ExpectedStrategyType, deployment.Spec.Strategy.Type)
@@ -225,24 +224,15 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 					"app.gitlab.com/app": "auto-devops-examples/minimal-ruby-app",
 					"app.gitlab.com/env": "prod",
 				}, deployment.Annotations)
-				require.Equal(t, map[string]string{
-					"chart":    chartName,
-					"heritage": "Helm",
-					"release":  tc.ExpectedRelease,
-					"tier":     "worker",
-					"track":    "stable",
-				}, deployment.Labels)
+				expectedLabels := expectedWorkerLabels(tc.ExpectedName, tc.ExpectedRelease, strings.TrimPrefix(expectedDeployment.ExpectedName, tc.ExpectedName+"-"))
+				require.Equal(t, expectedLabels, deployment.Labels)
 
 				require.Equal(t, map[string]string{
 					"app.gitlab.com/app":           "auto-devops-examples/minimal-ruby-app",
 					"app.gitlab.com/env":           "prod",
 					"checksum/application-secrets": "",
 				}, deployment.Spec.Template.Annotations)
-				require.Equal(t, map[string]string{
-					"release": tc.ExpectedRelease,
-					"tier":    "worker",
-					"track":   "stable",
-				}, deployment.Spec.Template.Labels)
+				require.Equal(t, expectedLabels, deployment.Spec.Template.Labels)
 
 				require.Len(t, deployment.Spec.Template.Spec.Containers, 1)
 				require.Equal(t, expectedDeployment.ExpectedCmd, deployment.Spec.Template.Spec.Containers[0].Command)
@@ -313,10 +303,9 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentList
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for i := range deployments.Items {
-				deployment := deployments.Items[i]
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for i := range deployments {
+				deployment := deployments[i]
 				require.Equal(
 					t,
 					tc.ExpectedImageRepository,
@@ -390,11 +379,9 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentList
-
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for i := range deployments.Items {
-				deployment := deployments.Items[i]
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for i := range deployments {
+				deployment := deployments[i]
 				require.Equal(
 					t,
 					tc.ExpectedImagePullSecrets,
@@ -500,11 +487,9 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentList
-
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for i := range deployments.Items {
-				deployment := deployments.Items[i]
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for i := range deployments {
+				deployment := deployments[i]
 				require.Equal(t, tc.ExpectedPodAnnotations, deployment.Spec.Template.ObjectMeta.Annotations)
 				for key, value := range tc.ExpectedPodLabels {
 					require.Equal(t, deployment.Spec.Template.ObjectMeta.Labels[key], value)
@@ -559,11 +544,9 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentList
-
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for i := range deployments.Items {
-				deployment := deployments.Items[i]
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for i := range deployments {
+				deployment := deployments[i]
 				require.Equal(t, tc.ExpectedHostAliases, deployment.Spec.Template.Spec.HostAliases)
 			}
 		})
@@ -610,11 +593,9 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentList
-
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for i := range deployments.Items {
-				deployment := deployments.Items[i]
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for i := range deployments {
+				deployment := deployments[i]
 				require.Equal(t, tc.ExpectedDnsConfig, deployment.Spec.Template.Spec.DNSConfig)
 			}
 		})
@@ -671,13 +652,12 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 				require.Equal(
 					t,
 					expectedDeployment.ExpectedHostNetwork,
@@ -731,6 +711,43 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 				},
 			},
 		},
+		{
+			CaseName: "per worker selector",
+			Release:  "production",
+			Values: map[string]string{
+				"perWorkerSelector":          "true",
+				"workers.worker1.command[0]": "echo",
+				"workers.worker1.command[1]": "worker1",
+				"workers.worker2.command[0]": "echo",
+				"workers.worker2.command[1]": "worker2",
+			},
+			ExpectedName:    "production",
+			ExpectedRelease: "production",
+			ExpectedDeployments: []workerDeploymentSelectorTestCase{
+				{
+					ExpectedName: "production-worker1",
+					ExpectedSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/component": "worker1",
+							"release":                     "production",
+							"tier":                        "worker",
+							"track":                       "stable",
+						},
+					},
+				},
+				{
+					ExpectedName: "production-worker2",
+					ExpectedSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/component": "worker2",
+							"release":                     "production",
+							"tier":                        "worker",
+							"track":                       "stable",
+						},
+					},
+				},
+			},
+		},
 	} {
 		t.Run(tc.CaseName, func(t *testing.T) {
 			namespaceName := "minimal-ruby-app-" + strings.ToLower(random.UniqueId())
@@ -749,30 +766,21 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 
 				require.Equal(t, expectedDeployment.ExpectedName, deployment.Name)
 
-				require.Equal(t, map[string]string{
-					"chart":    chartName,
-					"heritage": "Helm",
-					"release":  tc.ExpectedRelease,
-					"tier":     "worker",
-					"track":    "stable",
-				}, deployment.Labels)
+				expectedLabels := expectedWorkerLabels(tc.ExpectedName, tc.ExpectedRelease, strings.TrimPrefix(expectedDeployment.ExpectedName, tc.ExpectedName+"-"))
+				require.Equal(t, expectedLabels, deployment.Labels)
 
 				require.Equal(t, expectedDeployment.ExpectedSelector, deployment.Spec.Selector)
 
-				require.Equal(t, map[string]string{
-					"release": tc.ExpectedRelease,
-					"tier":    "worker",
-					"track":   "stable",
-				}, deployment.Spec.Template.Labels)
+				require.Equal(t, expectedLabels, deployment.Spec.Template.Labels)
+				require.Subset(t, deployment.Spec.Template.Labels, deployment.Spec.Selector.MatchLabels)
 			}
 		})
 	}
@@ -838,13 +846,12 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 				require.Equal(t, expectedDeployment.ExpectedServiceAccountName, deployment.Spec.Template.Spec.ServiceAccountName)
 			}
 		})
@@ -924,13 +931,12 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 				require.Equal(
 					t,
 					expectedDeployment.ExpectedServiceAccountName,
@@ -1046,13 +1052,12 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 				require.Equal(t, expectedDeployment.ExpectedName, deployment.Name)
 				require.Len(t, deployment.Spec.Template.Spec.Containers, 1)
 				require.Equal(t, expectedDeployment.ExpectedCmd, deployment.Spec.Template.Spec.Containers[0].Command)
@@ -1373,13 +1378,12 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 				require.Equal(t, expectedDeployment.ExpectedName, deployment.Name)
 				require.Len(t, deployment.Spec.Template.Spec.Containers, 1)
 				require.Equal(t, expectedDeployment.ExpectedCmd, deployment.Spec.Template.Spec.Containers[0].Command)
@@ -1504,13 +1508,12 @@ func TestWorkerDeploymentTemplate(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, tc.Release, []string{"templates/worker-deployment.yaml"}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			require.Len(t, deployments.Items, len(tc.ExpectedDeployments))
+			require.Len(t, deployments, len(tc.ExpectedDeployments))
 
 			for i, expectedDeployment := range tc.ExpectedDeployments {
-				deployment := deployments.Items[i]
+				deployment := deployments[i]
 				require.Equal(t, expectedDeployment.ExpectedName, deployment.Name)
 				require.Len(t, deployment.Spec.Template.Spec.Containers, 1)
 				require.Equal(t, expectedDeployment.ExpectedCmd, deployment.Spec.Template.Spec.Containers[0].Command)
@@ -1599,10 +1602,9 @@ func TestWorkerTemplateWithVolumeMounts(t *testing.T) {
 			}
 			output := mustRenderTemplate(t, opts, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
-			for _, deployment := range deployments.Items {
+			for _, deployment := range deployments {
 				for i, expectedVolume := range tc.expectedVolumes {
 					require.Equal(t, expectedVolume.Name, deployment.Spec.Template.Spec.Volumes[i].Name)
 					if deployment.Spec.Template.Spec.Volumes[i].PersistentVolumeClaim != nil {
@@ -1678,13 +1680,12 @@ func TestWorkerDatabaseUrlEnvironmentVariable(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, []string{tc.Template}, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
 
 			if tc.ExpectedDatabaseUrl != "" {
-				require.Contains(t, deployments.Items[0].Spec.Template.Spec.Containers[0].Env, coreV1.EnvVar{Name: "DATABASE_URL", Value: tc.ExpectedDatabaseUrl})
+				require.Contains(t, deployments[0].Spec.Template.Spec.Containers[0].Env, coreV1.EnvVar{Name: "DATABASE_URL", Value: tc.ExpectedDatabaseUrl})
 			} else {
-				for _, envVar := range deployments.Items[0].Spec.Template.Spec.Containers[0].Env {
+				for _, envVar := range deployments[0].Spec.Template.Spec.Containers[0].Env {
 					require.NotEqual(t, "DATABASE_URL", envVar.Name)
 				}
 			}
@@ -1692,6 +1693,64 @@ func TestWorkerDatabaseUrlEnvironmentVariable(t *testing.T) {
 	}
 }
 
+func TestWorkerDeploymentTemplateWithWaitForDependencies(t *testing.T) {
+	releaseName := "worker-wait-for-dependencies-test"
+	templates := []string{"templates/worker-deployment.yaml"}
+
+	opts := &helm.Options{
+		SetValues: map[string]string{
+			"waitForDependencies.enabled":             "true",
+			"application.secretName":                  "app-secret",
+			"workers.worker1.command[0]":              "echo",
+			"workers.worker2.command[0]":              "echo",
+			"workers.worker2.initContainers[0].name":  "myservice",
+			"workers.worker2.initContainers[0].image": "myimage:1",
+		},
+	}
+	output := mustRenderTemplate(t, opts, releaseName, templates, nil)
+
+	deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+	require.Len(t, deployments, 2)
+	waitForDependencies := coreV1.Container{
+		Name:            "wait-for-dependencies",
+		Image:           "ghcr.io/sshoc/gl-autodevops-minimal-port/wait-for:latest",
+		ImagePullPolicy: coreV1.PullAlways,
+		Args:            []string{"-timeout=5m", "-postgres"},
+		EnvFrom: []coreV1.EnvFromSource{
+			{SecretRef: &coreV1.SecretEnvSource{LocalObjectReference: coreV1.LocalObjectReference{Name: "app-secret"}}},
+		},
+	}
+	require.Equal(t, []coreV1.Container{waitForDependencies}, deployments[0].Spec.Template.Spec.InitContainers)
+	require.Equal(t, []coreV1.Container{waitForDependencies, {Name: "myservice", Image: "myimage:1"}}, deployments[1].Spec.Template.Spec.InitContainers)
+}
+
+func TestWorkerDeploymentTemplateWithAutoscaling(t *testing.T) {
+	releaseName := "worker-autoscaling-test"
+	templates := []string{"templates/worker-deployment.yaml"}
+
+	opts := &helm.Options{
+		SetValues: map[string]string{
+			"workers.worker1.command[0]":            "echo",
+			"workers.worker1.replicaCount":          "3",
+			"workers.worker2.command[0]":            "echo",
+			"workers.worker2.replicaCount":          "3",
+			"workers.worker2.hpa.enabled":           "true",
+			"workers.worker3.command[0]":            "echo",
+			"workers.worker3.replicaCount":          "3",
+			"workers.worker3.keda.enabled":          "true",
+			"workers.worker3.keda.triggers[0].type": "cpu",
+		},
+	}
+	output := mustRenderTemplate(t, opts, releaseName, templates, nil)
+
+	objects := mustDecodeObjects(t, output)
+	replicas := int32(3)
+	require.Equal(t, &replicas, mustGet[*appsV1.Deployment](t, objects, releaseName+"-worker1").Spec.Replicas)
+	// the HorizontalPodAutoscaler of hpa or of KEDA owns the replicas
+	require.Nil(t, mustGet[*appsV1.Deployment](t, objects, releaseName+"-worker2").Spec.Replicas)
+	require.Nil(t, mustGet[*appsV1.Deployment](t, objects, releaseName+"-worker3").Spec.Replicas)
+}
+
 func TestWorkerDeploymentTemplateWithExtraEnvFrom(t *testing.T) {
 	releaseName := "worker-deployment-with-extra-envfrom-test"
 	templates := []string{"templates/worker-deployment.yaml"}
@@ -1756,9 +1815,8 @@ func TestWorkerDeploymentTemplateWithExtraEnvFrom(t *testing.T) {
 			}
 			output := mustRenderTemplate(t, opts, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for _, deployment := range deployments {
 				require.Contains(t, deployment.Spec.Template.Spec.Containers[0].EnvFrom, tc.expectedEnvFrom)
 			}
 		})
@@ -1800,9 +1858,8 @@ func TestWorkerDeploymentTemplateWithExtraEnv(t *testing.T) {
 
 			output := mustRenderTemplate(t, options, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for _, deployment := range deployments {
 				require.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, tc.expectedEnv)
 			}
 		})
@@ -1834,9 +1891,8 @@ func TestWorkerDeploymentTemplateWithSecurityContext(t *testing.T) {
 			}
 			output := mustRenderTemplate(t, opts, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for _, deployment := range deployments {
 				require.Equal(t, *deployment.Spec.Template.Spec.SecurityContext.WindowsOptions.GMSACredentialSpecName, tc.expectedSecurityContextName)
 			}
 		})
@@ -1870,9 +1926,8 @@ func TestWorkerDeploymentTemplateWithContainerSecurityContext(t *testing.T) {
 			}
 			output := mustRenderTemplate(t, opts, releaseName, templates, nil)
 
-			var deployments deploymentAppsV1List
-			helm.UnmarshalK8SYaml(t, output, &deployments)
-			for _, deployment := range deployments.Items {
+			deployments := mustGetAll[*appsV1.Deployment](t, mustDecodeObjects(t, output))
+			for _, deployment := range deployments {
 				require.Equal(t, deployment.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities.Drop, tc.expectedSecurityContextCapabilities)
 			}
 		})
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/helm"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWorkerKedaTemplate(t *testing.T) {
+	templates := []string{"templates/worker-keda.yaml"}
+	releaseName := "worker-keda-test"
+	name := releaseName + "-worker1"
+
+	tcs := []struct {
+		name   string
+		values string
+
+		expectedReplicas      map[string]float64
+		expectedTriggers      []interface{}
+		expectedSecretTargets []interface{}
+
+		expectedErrorRegexp *regexp.Regexp
+	}{
+		{
+			name:                "defaults",
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/worker-keda.yaml in chart"),
+		},
+		{
+			name: "keda disabled",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    keda:
+      triggers:
+      - type: cpu
+`,
+			expectedErrorRegexp: regexp.MustCompile("could not find template templates/worker-keda.yaml in chart"),
+		},
+		{
+			name: "scale to zero by default",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    keda:
+      enabled: true
+      triggers:
+      - type: redis
+        metadata:
+          address: redis:6379
+          listName: default
+          listLength: "5"
+`,
+			expectedReplicas: map[string]float64{"minReplicaCount": 0, "maxReplicaCount": 5},
+			expectedTriggers: []interface{}{
+				map[string]interface{}{
+					"type":     "redis",
+					"metadata": map[string]interface{}{"address": "redis:6379", "listName": "default", "listLength": "5"},
+				},
+			},
+		},
+		{
+			name: "with authentication from the application secret",
+			values: `
+application:
+  secretName: app-secret
+workers:
+  worker1:
+    command: ["echo"]
+    keda:
+      enabled: true
+      minReplicaCount: 1
+      maxReplicaCount: 10
+      idleReplicaCount: 0
+      pollingInterval: 15
+      cooldownPeriod: 120
+      authentication:
+      - parameter: password
+        key: REDIS_PASSWORD
+      triggers:
+      - type: redis
+        metadata:
+          listName: default
+      - type: rabbitmq
+        metadata:
+          queueName: mails
+        authenticationRef:
+          name: rabbitmq
+`,
+			expectedReplicas: map[string]float64{
+				"minReplicaCount":  1,
+				"maxReplicaCount":  10,
+				"idleReplicaCount": 0,
+				"pollingInterval":  15,
+				"cooldownPeriod":   120,
+			},
+			expectedTriggers: []interface{}{
+				map[string]interface{}{
+					"type":              "redis",
+					"metadata":          map[string]interface{}{"listName": "default"},
+					"authenticationRef": map[string]interface{}{"name": name},
+				},
+				map[string]interface{}{
+					"type":              "rabbitmq",
+					"metadata":          map[string]interface{}{"queueName": "mails"},
+					"authenticationRef": map[string]interface{}{"name": "rabbitmq"},
+				},
+			},
+			expectedSecretTargets: []interface{}{
+				map[string]interface{}{"parameter": "password", "name": "app-secret", "key": "REDIS_PASSWORD"},
+			},
+		},
+		{
+			name: "with authentication, no application secret",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    keda:
+      enabled: true
+      authentication:
+      - parameter: password
+        key: REDIS_PASSWORD
+      triggers:
+      - type: redis
+`,
+			expectedErrorRegexp: regexp.MustCompile(`workers.worker1.keda.authentication: the TriggerAuthentication refers to application.secretName, which is not set`),
+		},
+		{
+			name: "without triggers",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    keda:
+      enabled: true
+`,
+			expectedErrorRegexp: regexp.MustCompile(`workers.worker1.keda.triggers: at least one trigger is required`),
+		},
+		{
+			name: "with hpa enabled",
+			values: `
+workers:
+  worker1:
+    command: ["echo"]
+    hpa:
+      enabled: true
+    keda:
+      enabled: true
+      triggers:
+      - type: cpu
+`,
+			expectedErrorRegexp: regexp.MustCompile(`workers.worker1: keda and hpa can't both be enabled`),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.CreateTemp("", "")
+			defer os.Remove(f.Name())
+			require.NoError(t, err)
+			f.WriteString(tc.values)
+
+			opts := &helm.Options{ValuesFiles: []string{f.Name()}}
+			output := mustRenderTemplate(t, opts, releaseName, templates, tc.expectedErrorRegexp)
+
+			if tc.expectedErrorRegexp != nil {
+				return
+			}
+
+			objects := mustDecodeObjects(t, output)
+			scaledObject := mustGetCustomResource(t, objects, "ScaledObject", name)
+			require.Equal(t, "keda.sh/v1alpha1", scaledObject.GetAPIVersion())
+			require.Equal(t, expectedWorkerLabels(releaseName, releaseName, "worker1"), scaledObject.GetLabels())
+			target, _, err := unstructured.NestedStringMap(scaledObject.Object, "spec", "scaleTargetRef")
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{"apiVersion": "apps/v1", "kind": "Deployment", "name": name}, target)
+			spec, _, err := unstructured.NestedMap(scaledObject.Object, "spec")
+			require.NoError(t, err)
+			for field, expected := range tc.expectedReplicas {
+				actual, ok, err := unstructured.NestedFloat64(spec, field)
+				require.NoError(t, err)
+				require.Truef(t, ok, "spec.%s is not set", field)
+				require.Equal(t, expected, actual, field)
+			}
+			require.Equal(t, tc.expectedTriggers, spec["triggers"])
+
+			if tc.expectedSecretTargets == nil {
+				require.NotContains(t, objects.keys(), "TriggerAuthentication/"+name)
+				return
+			}
+			auth := mustGetCustomResource(t, objects, "TriggerAuthentication", name)
+			secretTargets, _, err := unstructured.NestedSlice(auth.Object, "spec", "secretTargetRef")
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedSecretTargets, secretTargets)
+		})
+	}
+}
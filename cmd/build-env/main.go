@@ -0,0 +1,75 @@
+// Command build-env prints the environment of a build: the same K8S_SECRET_ and LC_K8S_SECRET_ variables and
+// secrets, SERVICE_ID and PUBLIC_URL that k8s-secret passes to the deployed application, see k8ssecret.Data.
+//
+// The workflow passes its contexts and the inputs in the environment:
+//
+//	SECRETS_CONTEXT    toJson(secrets)
+//	VARS_CONTEXT       toJson(vars)
+//	SERVICE_ID         vars.SERVICE_ID is used if empty
+//	PUBLIC_URL         inputs.PUBLIC_URL, vars.PUBLIC_URL is used if empty
+//
+// The formats are dotenv, shell-export for a file sh sources, and env-file for docker run --env-file and
+// pack build --env-file.
+//
+// Usage:
+//
+//	build-env -format shell-export [-o secrets.env]
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/envfile"
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/k8ssecret"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("build-env: ")
+
+	formatName := flag.String("format", "", "the format of the file: dotenv, shell-export or env-file")
+	output := flag.String("o", "", "write the environment to this file instead of stdout")
+	flag.Parse()
+	if *formatName == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	format, err := envfile.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	secrets, err := k8ssecret.ParseContext(os.Getenv("SECRETS_CONTEXT"))
+	if err != nil {
+		log.Fatalf("SECRETS_CONTEXT: %v", err)
+	}
+	vars, err := k8ssecret.ParseContext(os.Getenv("VARS_CONTEXT"))
+	if err != nil {
+		log.Fatalf("VARS_CONTEXT: %v", err)
+	}
+	env, err := k8ssecret.Data(k8ssecret.Input{
+		Secrets:   secrets,
+		Vars:      vars,
+		ServiceID: os.Getenv("SERVICE_ID"),
+		PublicURL: os.Getenv("PUBLIC_URL"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := envfile.Write(&b, env, format); err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		_, err = os.Stdout.WriteString(b.String())
+	} else {
+		err = os.WriteFile(*output, []byte(b.String()), 0o600)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
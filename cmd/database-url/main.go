@@ -0,0 +1,130 @@
+// Command database-url builds a DATABASE_URL from its parts, or splits one into POSTGRES_ variables.
+//
+// build prints the URL. The parts default to the environment:
+//
+//	POSTGRES_HOST, POSTGRES_PORT, POSTGRES_DB, POSTGRES_USER, POSTGRES_PASSWORD, POSTGRES_SSLMODE
+//
+// The password can't be passed as a flag, so that it doesn't show up in the process list.
+//
+// split prints POSTGRES_HOST, POSTGRES_PORT, POSTGRES_DB, POSTGRES_USER, POSTGRES_PASSWORD and POSTGRES_SSLMODE
+// as NAME=value lines for $GITHUB_ENV or docker's --env-file, from the URL in DATABASE_URL.
+//
+// Usage:
+//
+//	database-url build [-host db] [-port 5432] [-db app] [-user app] [-sslmode require] [-param name=value]...
+//	database-url split [-o postgres.env]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/dburl"
+)
+
+// paramsFlag collects the parameters of a repeated -param flag
+type paramsFlag map[string]string
+
+func (p paramsFlag) String() string {
+	return fmt.Sprint(map[string]string(p))
+}
+
+func (p paramsFlag) Set(param string) error {
+	name, value, ok := strings.Cut(param, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not a name=value pair", param)
+	}
+	p[name] = value
+	return nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("database-url: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "build":
+		build(os.Args[2:])
+	case "split":
+		split(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: database-url build [flags] | database-url split [flags]")
+	os.Exit(2)
+}
+
+func build(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	params := dburl.Params{Extra: paramsFlag{}}
+	flags.StringVar(&params.Host, "host", os.Getenv("POSTGRES_HOST"), "the host, $POSTGRES_HOST by default")
+	port := flags.String("port", os.Getenv("POSTGRES_PORT"), "the port, $POSTGRES_PORT or 5432 by default")
+	flags.StringVar(&params.Database, "db", os.Getenv("POSTGRES_DB"), "the database, $POSTGRES_DB by default")
+	flags.StringVar(&params.User, "user", os.Getenv("POSTGRES_USER"), "the user, $POSTGRES_USER by default")
+	flags.StringVar(&params.SSLMode, "sslmode", os.Getenv("POSTGRES_SSLMODE"), "the sslmode, $POSTGRES_SSLMODE by default")
+	flags.StringVar(&params.Scheme, "scheme", "postgres", "the scheme of the URL")
+	flags.Var(paramsFlag(params.Extra), "param", "an extra parameter as name=value, can be repeated")
+	_ = flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	params.Password = os.Getenv("POSTGRES_PASSWORD")
+	if *port != "" {
+		var err error
+		if params.Port, err = strconv.Atoi(*port); err != nil {
+			log.Fatalf("%s is not a valid port", *port)
+		}
+	}
+
+	u, err := dburl.Build(params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(u)
+}
+
+func split(args []string) {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	output := flags.String("o", "", "write the variables to this file instead of stdout")
+	_ = flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	params, err := dburl.Parse(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	variables, err := params.PostgresVariables()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if names := params.ExtraNames(); len(names) > 0 {
+		log.Printf("the parameters %s have no POSTGRES_ variable, they are left out", strings.Join(names, ", "))
+	}
+
+	var b strings.Builder
+	for _, v := range variables {
+		fmt.Fprintf(&b, "%s=%s\n", v.Name, v.Value)
+	}
+	if *output == "" {
+		_, err = os.Stdout.WriteString(b.String())
+	} else {
+		err = os.WriteFile(*output, []byte(b.String()), 0o600)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+// Command deploy-values prints the auto-deploy-values.yaml the auto-deploy-app chart is installed with.
+//
+// The values are computed from the flags, and the values files given with -values are merged on top in order,
+// the later ones winning. Values files that don't exist are skipped. The variables listed in
+// deployvalues.Variables are substituted in the values files, POSTGRES_HOST and the other database settings
+// are taken from the environment.
+//
+// Usage:
+//
+//	deploy-values -image ghcr.io/org/app:1a2b3c4 -port 5000 [flags] \
+//	  -values caller/.gitlab/auto-deploy-values.yaml -values caller/.github/auto-deploy-values.yaml \
+//	  [-o auto-deploy-values.yaml]
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/deployvalues"
+)
+
+// valuesFlag collects the paths of a repeated -values flag
+type valuesFlag []string
+
+func (v *valuesFlag) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *valuesFlag) Set(path string) error {
+	*v = append(*v, path)
+	return nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("deploy-values: ")
+
+	var in deployvalues.Input
+	var valuesFiles valuesFlag
+	flag.StringVar(&in.Image, "image", "", "the image to deploy with its tag")
+	flag.StringVar(&in.AppName, "app-name", "", "APP_NAME of the deployment")
+	flag.StringVar(&in.RepoURL, "repo-url", "", "the URL of the repository of the application")
+	flag.StringVar(&in.ServiceID, "service-id", "", "SERVICE_ID, added as the ID label")
+	flag.StringVar(&in.PublicURL, "public-url", "", "the URL the application is served at, https://<environment-short>.<base-domain> if empty")
+	flag.StringVar(&in.AppRoot, "app-root", "/", "the path the probes check, and the app-root of the ingress if it is not /")
+	flag.IntVar(&in.Port, "port", 0, "the port the application listens on")
+	flag.StringVar(&in.RefName, "ref-name", "", "the slug of the branch")
+	flag.StringVar(&in.EnvironmentShort, "environment-short", "", "the slug of the environment")
+	flag.StringVar(&in.KubeIngressBaseDomain, "base-domain", "", "KUBE_INGRESS_BASE_DOMAIN")
+	noAdditionalHosts := flag.Bool("no-additional-hosts", false, "don't add <app-name>-<ref-name>.<base-domain> as host")
+	flag.Var(&valuesFiles, "values", "a values file to merge on top, can be repeated")
+	output := flag.String("o", "", "write the values to this file instead of stdout")
+	flag.Parse()
+	if in.Image == "" || in.Port == 0 || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	in.AdditionalHosts = !*noAdditionalHosts
+
+	in.Env = make(map[string]string)
+	for _, name := range deployvalues.Variables {
+		in.Env[name] = os.Getenv(name)
+	}
+
+	var layers []deployvalues.Layer
+	for _, path := range valuesFiles {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Printf("%s doesn't exist, skipping it", path)
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		layers = append(layers, deployvalues.Layer{Name: path, Data: data})
+	}
+
+	values, err := deployvalues.Generate(in, layers...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(values)
+	} else {
+		err = os.WriteFile(*output, values, 0o600)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
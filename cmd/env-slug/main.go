@@ -0,0 +1,53 @@
+// Command env-slug prints the names of a deployment derived from the git ref, see package envslug for the rules.
+//
+// The names are printed as name=value lines for $GITHUB_OUTPUT:
+//
+//	environment, environment_short, ref_name
+//	release_name, secret_name, namespace, host_label    if -app-name is given, namespace also with -namespace
+//
+// Usage:
+//
+//	env-slug [-ref refs/heads/main] [-app-name "$APP_NAME"] [-namespace "$KUBE_NAMESPACE"] >> "$GITHUB_OUTPUT"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/envslug"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("env-slug: ")
+
+	ref := flag.String("ref", os.Getenv("GITHUB_REF"), "the git ref or branch, $GITHUB_REF by default")
+	appName := flag.String("app-name", "", "APP_NAME of the deployment")
+	namespace := flag.String("namespace", "", "KUBE_NAMESPACE, the namespace is derived from APP_NAME if empty")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	names, err := envslug.Compute(*ref, *appName, *namespace)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, output := range []struct{ name, value string }{
+		{"environment", names.Environment},
+		{"environment_short", names.Short},
+		{"ref_name", names.RefName},
+		{"release_name", names.ReleaseName},
+		{"secret_name", names.SecretName},
+		{"namespace", names.Namespace},
+		{"host_label", names.HostLabel},
+	} {
+		if output.value != "" {
+			fmt.Printf("%s=%s\n", output.name, output.value)
+		}
+	}
+}
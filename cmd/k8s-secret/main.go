@@ -0,0 +1,78 @@
+// Command k8s-secret prints the Secret manifest with the environment of the deployed application.
+//
+// The workflow passes its contexts and the values it computed in the environment:
+//
+//	SECRETS_CONTEXT    toJson(secrets)
+//	VARS_CONTEXT       toJson(vars)
+//	SERVICE_ID         inputs.SERVICE_ID, vars.SERVICE_ID is used if empty
+//	PUBLIC_URL         inputs.PUBLIC_URL, vars.PUBLIC_URL is used if empty
+//	POSTGRES_HOST, POSTGRES_USER, POSTGRES_PASSWORD, POSTGRES_DB, DATABASE_URL, DEPLOY_DATABASE
+//
+// Usage:
+//
+//	k8s-secret -name "$APP_NAME" [-o secrets.yaml]
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/envslug"
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/k8ssecret"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("k8s-secret: ")
+
+	appName := flag.String("name", "", "APP_NAME of the deployment, the Secret is named after it, see envslug.SecretName")
+	output := flag.String("o", "", "write the manifest to this file instead of stdout")
+	flag.Parse()
+	if *appName == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	secrets, err := k8ssecret.ParseContext(os.Getenv("SECRETS_CONTEXT"))
+	if err != nil {
+		log.Fatalf("SECRETS_CONTEXT: %v", err)
+	}
+	vars, err := k8ssecret.ParseContext(os.Getenv("VARS_CONTEXT"))
+	if err != nil {
+		log.Fatalf("VARS_CONTEXT: %v", err)
+	}
+
+	data, err := k8ssecret.Data(k8ssecret.Input{
+		Secrets:          secrets,
+		Vars:             vars,
+		ServiceID:        os.Getenv("SERVICE_ID"),
+		PublicURL:        os.Getenv("PUBLIC_URL"),
+		PostgresHost:     os.Getenv("POSTGRES_HOST"),
+		PostgresUser:     os.Getenv("POSTGRES_USER"),
+		PostgresPassword: os.Getenv("POSTGRES_PASSWORD"),
+		PostgresDB:       os.Getenv("POSTGRES_DB"),
+		DatabaseURL:      os.Getenv("DATABASE_URL"),
+		DeployDatabase:   os.Getenv("DEPLOY_DATABASE"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	name, err := envslug.SecretName(*appName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	manifest, err := k8ssecret.Manifest(name, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.WriteString(manifest)
+	} else {
+		err = os.WriteFile(*output, []byte(manifest), 0o600)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
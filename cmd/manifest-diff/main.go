@@ -0,0 +1,136 @@
+// Command manifest-diff prints what helm upgrade --install will change in a release as Markdown for the job
+// summary, see package manifestdiff.
+//
+// The chart is rendered with the values files and the --set values like helm upgrade does, and compared with
+// the manifest of the deployed release as helm get manifest prints it. An empty or missing -deployed file
+// means the release is installed for the first time. The values of the environment variables given with
+// -redact-env are replaced wherever they show up in the diff, the values of Secrets are never shown.
+//
+// The arguments after the flags are further arguments of helm upgrade, like HELM_UPGRADE_EXTRA_ARGS: their
+// --values, -f, --set and --set-string are applied after the flags, the others are logged and ignored. With
+// -kube-capabilities the chart is rendered with the Kubernetes version and API versions of the cluster of the
+// kubeconfig, like helm upgrade does, else with helm's defaults.
+//
+// With -fail-on-deletion the command fails after printing the diff if a PersistentVolumeClaim or a Secret
+// would be deleted.
+//
+// Usage:
+//
+//	helm get manifest "$RELEASE" > deployed.yaml || true
+//	manifest-diff -chart .github/auto-deploy-app -release "$RELEASE" -namespace "$KUBE_NAMESPACE" \
+//	  -values auto-deploy-values.yaml [-set key=value] -deployed deployed.yaml [-kube-capabilities] \
+//	  [-redact-env DATABASE_URL] [-fail-on-deletion] [-- $HELM_UPGRADE_EXTRA_ARGS] >> "$GITHUB_STEP_SUMMARY"
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/manifestdiff"
+)
+
+// protectedKinds are the kinds -fail-on-deletion protects, deleting them loses data
+var protectedKinds = []string{"PersistentVolumeClaim", "Secret"}
+
+// listFlag collects the values of a repeated flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("manifest-diff: ")
+
+	var opts manifestdiff.RenderOptions
+	var valuesFiles, sets, redactEnv listFlag
+	flag.StringVar(&opts.Chart, "chart", "", "the path of the chart")
+	flag.StringVar(&opts.Release, "release", "", "the name of the release")
+	flag.StringVar(&opts.Namespace, "namespace", "", "the namespace of the release")
+	flag.Var(&valuesFiles, "values", "a values file, can be repeated")
+	flag.Var(&sets, "set", "a value like helm --set, can be repeated")
+	deployed := flag.String("deployed", "", "the output of helm get manifest for the release")
+	kubeCapabilities := flag.Bool("kube-capabilities", false, "render with the capabilities of the cluster of the kubeconfig")
+	flag.Var(&redactEnv, "redact-env", "an environment variable whose value is hidden in the diff, can be repeated")
+	failOnDeletion := flag.Bool("fail-on-deletion", false, "fail if a PersistentVolumeClaim or a Secret would be deleted")
+	output := flag.String("o", "", "write the diff to this file instead of stdout")
+	flag.Parse()
+	if opts.Chart == "" || opts.Release == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts.ValuesFiles = valuesFiles
+	opts.Set = sets
+	ignored, err := opts.AddHelmArgs(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, arg := range ignored {
+		log.Printf("helm argument %s is not part of the diff", arg)
+	}
+	if *kubeCapabilities {
+		if opts.Capabilities, err = manifestdiff.KubeCapabilities(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var deployedManifest []byte
+	if *deployed != "" {
+		var err error
+		deployedManifest, err = os.ReadFile(*deployed)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Fatal(err)
+		}
+	}
+	opts.Upgrade = len(deployedManifest) > 0
+	deployedObjects, err := manifestdiff.Parse(string(deployedManifest))
+	if err != nil {
+		log.Fatalf("%s: %v", *deployed, err)
+	}
+
+	manifest, err := manifestdiff.Render(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	renderedObjects, err := manifestdiff.Parse(manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var redact []string
+	for _, name := range redactEnv {
+		redact = append(redact, os.Getenv(name))
+	}
+	changes := manifestdiff.Diff(deployedObjects, renderedObjects)
+	var b strings.Builder
+	if err := manifestdiff.Markdown(&b, opts.Release, changes, redact); err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		_, err = os.Stdout.WriteString(b.String())
+	} else {
+		err = os.WriteFile(*output, []byte(b.String()), 0o600)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if deletions := manifestdiff.Deletions(changes, protectedKinds...); len(deletions) > 0 {
+		for _, deletion := range deletions {
+			log.Printf("%s would be deleted", deletion.Object)
+		}
+		if *failOnDeletion {
+			log.Fatal("refusing to delete a PersistentVolumeClaim or a Secret, see the diff")
+		}
+	}
+}
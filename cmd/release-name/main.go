@@ -0,0 +1,77 @@
+// Command release-name prints the names of the helm release and of the Secret for APP_NAME, see package envslug
+// for the rules, and reports the releases in a helm list -o json document that may collide with them. If the
+// document has the release of APP_NAME from before release names got a hash, see envslug.DeployedReleaseName,
+// its name is printed, so that the release is upgraded.
+//
+// The names are printed as name=value lines for $GITHUB_OUTPUT:
+//
+//	release_name, secret_name
+//
+// Collisions are logged as workflow warnings, with -fail-on-collision they fail the command.
+//
+// Usage:
+//
+//	helm list --all -o json > releases.json
+//	release-name -app-name "$APP_NAME" [-namespace "$KUBE_NAMESPACE"] [-helm-list releases.json] [-fail-on-collision]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/envslug"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("release-name: ")
+
+	appName := flag.String("app-name", "", "APP_NAME of the deployment")
+	namespace := flag.String("namespace", "", "the namespace of the release, releases in other namespaces don't collide")
+	helmList := flag.String("helm-list", "", "the output of helm list -o json to check for collisions")
+	failOnCollision := flag.Bool("fail-on-collision", false, "fail if a release collides")
+	flag.Parse()
+	if *appName == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	releaseName, err := envslug.ReleaseName(*appName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	secretName, err := envslug.SecretName(*appName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var collisions []envslug.Collision
+	if *helmList != "" {
+		data, err := os.ReadFile(*helmList)
+		if err != nil {
+			log.Fatal(err)
+		}
+		releases, err := envslug.ParseReleases(data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if releaseName, err = envslug.DeployedReleaseName(*appName, *namespace, releases); err != nil {
+			log.Fatal(err)
+		}
+		if collisions, err = envslug.Collisions(*appName, *namespace, releases); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, collision := range collisions {
+		// the workflow command makes the collision an annotation of the run, the prefix would hide it
+		fmt.Fprintf(os.Stderr, "::warning title=Release %s::%s\n", collision.Release.Name, collision.Reason)
+	}
+	if len(collisions) > 0 && *failOnCollision {
+		log.Fatalf("releases collide with %s, see the warnings", releaseName)
+	}
+
+	fmt.Printf("release_name=%s\n", releaseName)
+	fmt.Printf("secret_name=%s\n", secretName)
+}
@@ -0,0 +1,83 @@
+// Command retention-plan plans which versions of a container package to delete, see package retention.
+//
+// The versions are read as gh api --paginate prints them, the deployed images are image references separated
+// by white space, like kubectl get -o jsonpath='{..image}' prints them. The plan is printed as Markdown for the
+// job summary and written as JSON with -o, for the step that deletes the versions after the plan was reviewed.
+// Nothing is deleted by this command.
+//
+// Usage:
+//
+//	gh api --paginate "/orgs/$ORG/packages/container/$PACKAGE/versions" > versions.json
+//	retention-plan -package "$PACKAGE" -versions versions.json -policy .github/container-retention.yaml \
+//	  [-deployed deployed.txt] [-o plan.json] >> "$GITHUB_STEP_SUMMARY"
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/retention"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("retention-plan: ")
+
+	pkg := flag.String("package", "", "the name of the container package, like app or tests/app")
+	versionsFile := flag.String("versions", "", "the file with the versions of the package")
+	policyFile := flag.String("policy", "", "the retention policy")
+	deployedFile := flag.String("deployed", "", "the file with the deployed images")
+	output := flag.String("o", "", "write the plan as JSON to this file")
+	flag.Parse()
+	if *pkg == "" || *versionsFile == "" || *policyFile == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*policyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, err := retention.ParsePolicy(data)
+	if err != nil {
+		log.Fatalf("%s: %v", *policyFile, err)
+	}
+	f, err := os.Open(*versionsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	versions, err := retention.ParseVersions(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", *versionsFile, err)
+	}
+	var deployed []retention.Image
+	if *deployedFile != "" {
+		f, err := os.Open(*deployedFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		deployed, err = retention.ParseImages(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	plan := retention.NewPlan(*pkg, versions, policy, deployed, time.Now())
+	if err := retention.Markdown(os.Stdout, plan); err != nil {
+		log.Fatal(err)
+	}
+	if *output != "" {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*output, append(data, '\n'), 0o600); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
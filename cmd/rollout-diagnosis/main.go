@@ -0,0 +1,68 @@
+// Command rollout-diagnosis explains why helm upgrade --atomic --wait failed, as Markdown for the job summary,
+// see package rollout.
+//
+// The objects are the output of kubectl get pods,jobs,events -o json in the namespace of the release, the
+// optional logs the output of kubectl logs --prefix. The pods and jobs are selected by the release label and
+// the labels of -l, like track=stable,tier=web.
+//
+// Usage:
+//
+//	kubectl get pods,jobs,events -o json > rollout.json
+//	kubectl logs -l release="$RELEASE" --all-containers --prefix --tail 20 > rollout.log || true
+//	rollout-diagnosis -release "$RELEASE" [-l track=stable] -objects rollout.json [-logs rollout.log] \
+//	  >> "$GITHUB_STEP_SUMMARY"
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/rollout"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rollout-diagnosis: ")
+
+	release := flag.String("release", "", "the name of the release")
+	selector := flag.String("l", "", "the labels the pods and jobs must have besides the release, like track=stable,tier=web")
+	objectsFile := flag.String("objects", "", "the output of kubectl get pods,jobs,events -o json")
+	logsFile := flag.String("logs", "", "the output of kubectl logs --prefix")
+	flag.Parse()
+	if *release == "" || *objectsFile == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	labels, err := rollout.ParseSelector(*selector)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Open(*objectsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	objs, err := rollout.ParseObjects(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", *objectsFile, err)
+	}
+	logs := rollout.Logs{}
+	if *logsFile != "" {
+		f, err := os.Open(*logsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logs, err = rollout.ParseLogs(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *logsFile, err)
+		}
+	}
+
+	objs = objs.Select(*release, labels)
+	if err := rollout.Markdown(os.Stdout, *release, rollout.Diagnose(objs, logs), objs); err != nil {
+		log.Fatal(err)
+	}
+}
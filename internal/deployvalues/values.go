@@ -0,0 +1,310 @@
+// Package deployvalues generates the auto-deploy-values.yaml the deploy workflows install the auto-deploy-app
+// chart with: values computed from the inputs of the workflow, with the values files of the caller merged on top.
+package deployvalues
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/envslug"
+)
+
+// Variables is the list of variables that are substituted in the values files of the caller, as `$name` or
+// `${name}`. Other variables are left as they are.
+var Variables = []string{
+	"repo_url", "ref_name", "environment_short", "kube_ingress_base_domain", "public_url", "service_id",
+	"docker_tag", "repository", "tag", "app_name", "app_name_in_url",
+	"POSTGRES_ENABLED", "POSTGRES_HOST", "POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB", "POSTGRES_VERSION",
+	"POSTGRES_SIZE", "DATABASE_URL", "DEPLOY_DATABASE",
+}
+
+// variableRegexp matches a variable reference like envsubst does
+var variableRegexp = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+// Input are the inputs of the deploy workflow the values are computed from.
+type Input struct {
+	Image     string // the image to deploy with its tag, like ghcr.io/org/app:1a2b3c4
+	AppName   string // APP_NAME
+	RepoURL   string // the URL of the repository of the application
+	ServiceID string // added as the ID label to the objects of the release
+	// PublicURL is the URL the application is served at, https://<EnvironmentShort>.<KubeIngressBaseDomain>
+	// if empty
+	PublicURL             string
+	AppRoot               string // the path the probes check, and the app-root of the ingress if it is not /
+	Port                  int    // the port the application listens on
+	RefName               string // the slug of the branch
+	EnvironmentShort      string // the slug of the environment
+	KubeIngressBaseDomain string
+	// AdditionalHosts adds <host label>.<base domain> as host of the ingress, besides the public URL, see
+	// envslug.HostLabel
+	AdditionalHosts bool
+
+	// Env holds the values of the variables in the environment of the workflow, like POSTGRES_HOST, for
+	// the substitution in the values files of the caller
+	Env map[string]string
+}
+
+// Layer is a values file that is merged on top of the generated values.
+type Layer struct {
+	Name string
+	Data []byte
+}
+
+// values are the generated values, in the order of the keys in auto-deploy-values.yaml
+type values struct {
+	ReplicaCount int               `yaml:"replicaCount"`
+	Image        imageValues       `yaml:"image"`
+	ExtraLabels  map[string]string `yaml:"extraLabels"`
+	Gitlab       gitlabValues      `yaml:"gitlab"`
+	Service      serviceValues     `yaml:"service"`
+	Ingress      ingressValues     `yaml:"ingress"`
+	Liveness     probeValues       `yaml:"livenessProbe"`
+	Readiness    probeValues       `yaml:"readinessProbe"`
+}
+
+type imageValues struct {
+	Repository string   `yaml:"repository"`
+	Tag        string   `yaml:"tag"`
+	PullPolicy string   `yaml:"pullPolicy"`
+	Secrets    []string `yaml:"secrets"`
+}
+
+type gitlabValues struct {
+	App    string `yaml:"app"`
+	EnvURL string `yaml:"envURL"`
+}
+
+type serviceValues struct {
+	Enabled         bool     `yaml:"enabled"`
+	Name            string   `yaml:"name"`
+	URL             string   `yaml:"url"`
+	AdditionalHosts []string `yaml:"additionalHosts,omitempty"`
+	Type            string   `yaml:"type"`
+	ExternalPort    int      `yaml:"externalPort"`
+	InternalPort    int      `yaml:"internalPort"`
+}
+
+type ingressValues struct {
+	Enabled     bool              `yaml:"enabled"`
+	Path        string            `yaml:"path"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type probeValues struct {
+	Path                string `yaml:"path"`
+	InitialDelaySeconds int    `yaml:"initialDelaySeconds"`
+	TimeoutSeconds      int    `yaml:"timeoutSeconds"`
+	Scheme              string `yaml:"scheme"`
+	ProbeType           string `yaml:"probeType"`
+}
+
+// Generate returns the auto-deploy-values.yaml for the inputs, with the layers merged on top in order, see Merge.
+// The variables in the layers are substituted before they are parsed, see Substitute.
+func Generate(in Input, layers ...Layer) ([]byte, error) {
+	generated, err := defaults(in)
+	if err != nil {
+		return nil, err
+	}
+	b, err := yaml.Marshal(generated)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	variables := in.variables()
+	for _, layer := range layers {
+		var layerDoc yaml.Node
+		if err := yaml.Unmarshal([]byte(Substitute(string(layer.Data), variables)), &layerDoc); err != nil {
+			return nil, fmt.Errorf("%s: %w", layer.Name, err)
+		}
+		if len(layerDoc.Content) == 0 {
+			continue
+		}
+		if err := Merge(doc.Content[0], resolveAliases(layerDoc.Content[0])); err != nil {
+			return nil, fmt.Errorf("%s: %w", layer.Name, err)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("# generated by cmd/deploy-values\n")
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func defaults(in Input) (values, error) {
+	if in.Image == "" {
+		return values{}, errors.New("the image is required")
+	}
+	if in.Port <= 0 || in.Port > 65535 {
+		return values{}, fmt.Errorf("%d is not a valid port", in.Port)
+	}
+	repository, tag := in.splitImage()
+	if in.PublicURL == "" && (in.EnvironmentShort == "" || in.KubeIngressBaseDomain == "") {
+		return values{}, errors.New("the public URL or the environment and the base domain are required")
+	}
+	publicURL := in.publicURL()
+	if u, err := url.Parse(publicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return values{}, fmt.Errorf("the public URL %q is not valid", publicURL)
+	}
+	appRoot := in.AppRoot
+	if appRoot == "" {
+		appRoot = "/"
+	}
+
+	v := values{
+		ReplicaCount: 1,
+		Image:        imageValues{Repository: repository, Tag: tag, PullPolicy: "Always", Secrets: []string{}},
+		ExtraLabels:  map[string]string{"ID": in.ServiceID},
+		Gitlab:       gitlabValues{App: in.AppName, EnvURL: in.RepoURL},
+		Service: serviceValues{
+			Enabled:      true,
+			Name:         "web",
+			URL:          publicURL,
+			Type:         "ClusterIP",
+			ExternalPort: in.Port,
+			InternalPort: in.Port,
+		},
+		Ingress: ingressValues{
+			Enabled:     true,
+			Path:        "/",
+			Annotations: map[string]string{"kubernetes.io/ingressClassName": "nginx"},
+		},
+		Liveness:  probeValues{Path: appRoot, InitialDelaySeconds: 15, TimeoutSeconds: 15, Scheme: "HTTP", ProbeType: "httpGet"},
+		Readiness: probeValues{Path: appRoot, InitialDelaySeconds: 5, TimeoutSeconds: 3, Scheme: "HTTP", ProbeType: "httpGet"},
+	}
+	if in.AdditionalHosts {
+		hostLabel, err := envslug.HostLabel(in.AppName, in.RefName)
+		if err != nil {
+			return values{}, err
+		}
+		v.Service.AdditionalHosts = []string{hostLabel + "." + in.KubeIngressBaseDomain}
+	}
+	if appRoot != "/" {
+		v.Ingress.Annotations["nginx.ingress.kubernetes.io/app-root"] = appRoot
+	}
+	return v, nil
+}
+
+// splitImage splits the image into the repository and the tag. A port of the registry is not a tag.
+func (in Input) splitImage() (string, string) {
+	i := strings.LastIndex(in.Image, ":")
+	if i < 0 || strings.Contains(in.Image[i:], "/") {
+		return in.Image, ""
+	}
+	return in.Image[:i], in.Image[i+1:]
+}
+
+func (in Input) publicURL() string {
+	if in.PublicURL != "" {
+		return in.PublicURL
+	}
+	return "https://" + in.EnvironmentShort + "." + in.KubeIngressBaseDomain
+}
+
+func (in Input) appNameInURL() string {
+	return strings.ToLower(strings.NewReplacer(" ", "-", "_", "-").Replace(in.AppName))
+}
+
+// variables returns the values of Variables for the inputs.
+func (in Input) variables() map[string]string {
+	repository, tag := in.splitImage()
+	variables := map[string]string{
+		"repo_url":                 in.RepoURL,
+		"ref_name":                 in.RefName,
+		"environment_short":        in.EnvironmentShort,
+		"kube_ingress_base_domain": in.KubeIngressBaseDomain,
+		"public_url":               in.publicURL(),
+		"service_id":               in.ServiceID,
+		"docker_tag":               in.Image,
+		"repository":               repository,
+		"tag":                      tag,
+		"app_name":                 in.AppName,
+		"app_name_in_url":          in.appNameInURL(),
+	}
+	for _, name := range Variables {
+		if _, ok := variables[name]; !ok {
+			variables[name] = in.Env[name]
+		}
+	}
+	return variables
+}
+
+// Substitute replaces the references to the variables in text like `envsubst` does when it is given the list of
+// variables: references to other variables are kept, variables without a value are replaced by nothing.
+func Substitute(text string, variables map[string]string) string {
+	return variableRegexp.ReplaceAllStringFunc(text, func(reference string) string {
+		match := variableRegexp.FindStringSubmatch(reference)
+		name := match[1] + match[2]
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		return reference
+	})
+}
+
+// Merge merges the mapping src into the mapping dst like helm merges values files: mappings are merged key by
+// key, other values replace the value in dst and null removes the key from dst. New keys are added at the end.
+func Merge(dst *yaml.Node, src *yaml.Node) error {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: the values must be a mapping", src.Line)
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		j := mappingIndex(dst, key.Value)
+		switch {
+		case value.Tag == "!!null" && j >= 0:
+			dst.Content = append(dst.Content[:j], dst.Content[j+2:]...)
+		case value.Tag == "!!null":
+		case j >= 0 && dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			if err := Merge(dst.Content[j+1], value); err != nil {
+				return err
+			}
+		case j >= 0:
+			dst.Content[j+1] = value
+		default:
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+	return nil
+}
+
+// mappingIndex returns the index of the key in the content of a mapping, or -1.
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveAliases replaces aliases by copies of their anchored nodes, the anchors are not merged with them.
+func resolveAliases(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		return resolveAliases(node.Alias)
+	}
+	resolved := *node
+	resolved.Anchor = ""
+	resolved.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		resolved.Content[i] = resolveAliases(child)
+	}
+	return &resolved
+}
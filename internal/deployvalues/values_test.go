@@ -0,0 +1,340 @@
+package deployvalues
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	appsV1 "k8s.io/api/apps/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+const chartPath = "../../.github/auto-deploy-app"
+
+var input = Input{
+	Image:                 "ghcr.io/sshoc/app:1a2b3c4",
+	AppName:               "My_App",
+	RepoURL:               "git://github.com/SSHOC/app.git",
+	ServiceID:             "12345",
+	AppRoot:               "/",
+	Port:                  5000,
+	RefName:               "feature-search",
+	EnvironmentShort:      "search",
+	KubeIngressBaseDomain: "example.com",
+	AdditionalHosts:       true,
+	Env:                   map[string]string{"POSTGRES_HOST": "my-app-postgres", "POSTGRES_DB": "app"},
+}
+
+func TestGenerate(t *testing.T) {
+	tcs := []struct {
+		name   string
+		modify func(in *Input)
+		layers []Layer
+
+		expectedValues string
+		expectedError  string
+	}{
+		{
+			name: "generated",
+			expectedValues: `# generated by cmd/deploy-values
+replicaCount: 1
+image:
+  repository: ghcr.io/sshoc/app
+  tag: 1a2b3c4
+  pullPolicy: Always
+  secrets: []
+extraLabels:
+  ID: "12345"
+gitlab:
+  app: My_App
+  envURL: git://github.com/SSHOC/app.git
+service:
+  enabled: true
+  name: web
+  url: https://search.example.com
+  additionalHosts:
+    - my-app-feature-search.example.com
+  type: ClusterIP
+  externalPort: 5000
+  internalPort: 5000
+ingress:
+  enabled: true
+  path: /
+  annotations:
+    kubernetes.io/ingressClassName: nginx
+livenessProbe:
+  path: /
+  initialDelaySeconds: 15
+  timeoutSeconds: 15
+  scheme: HTTP
+  probeType: httpGet
+readinessProbe:
+  path: /
+  initialDelaySeconds: 5
+  timeoutSeconds: 3
+  scheme: HTTP
+  probeType: httpGet
+`,
+		},
+		{
+			name: "app root, public URL, no additional hosts and a registry with a port",
+			modify: func(in *Input) {
+				in.Image = "registry.example.com:5000/app:v1"
+				in.AppRoot = "/app"
+				in.PublicURL = "https://app.example.org"
+				in.AdditionalHosts = false
+			},
+			layers: []Layer{{Name: "empty.yaml", Data: []byte("# nothing to change\n")}},
+			expectedValues: `# generated by cmd/deploy-values
+replicaCount: 1
+image:
+  repository: registry.example.com:5000/app
+  tag: v1
+  pullPolicy: Always
+  secrets: []
+extraLabels:
+  ID: "12345"
+gitlab:
+  app: My_App
+  envURL: git://github.com/SSHOC/app.git
+service:
+  enabled: true
+  name: web
+  url: https://app.example.org
+  type: ClusterIP
+  externalPort: 5000
+  internalPort: 5000
+ingress:
+  enabled: true
+  path: /
+  annotations:
+    kubernetes.io/ingressClassName: nginx
+    nginx.ingress.kubernetes.io/app-root: /app
+livenessProbe:
+  path: /app
+  initialDelaySeconds: 15
+  timeoutSeconds: 15
+  scheme: HTTP
+  probeType: httpGet
+readinessProbe:
+  path: /app
+  initialDelaySeconds: 5
+  timeoutSeconds: 3
+  scheme: HTTP
+  probeType: httpGet
+`,
+		},
+		{
+			name: "layers are merged in order with substituted variables",
+			layers: []Layer{
+				{Name: ".gitlab/auto-deploy-values.yaml", Data: []byte(`replicaCount: 2
+service:
+  url: "https://$environment_short.${kube_ingress_base_domain}/$unknown"
+  additionalHosts: null
+  externalPort: 8080
+ingress:
+  annotations:
+    nginx.ingress.kubernetes.io/proxy-body-size: 10m
+postgresql:
+  host: $POSTGRES_HOST
+  database: ${POSTGRES_DB}
+  user: "$POSTGRES_USER"
+`)},
+				{Name: ".github/auto-deploy-values.yaml", Data: []byte(`base: &probe
+  path: /health
+livenessProbe: *probe
+readinessProbe:
+  path: /ready
+replicaCount: 3
+image:
+  secrets:
+    - name: pull
+`)},
+			},
+			expectedValues: `# generated by cmd/deploy-values
+replicaCount: 3
+image:
+  repository: ghcr.io/sshoc/app
+  tag: 1a2b3c4
+  pullPolicy: Always
+  secrets:
+    - name: pull
+extraLabels:
+  ID: "12345"
+gitlab:
+  app: My_App
+  envURL: git://github.com/SSHOC/app.git
+service:
+  enabled: true
+  name: web
+  url: "https://search.example.com/$unknown"
+  type: ClusterIP
+  externalPort: 8080
+  internalPort: 5000
+ingress:
+  enabled: true
+  path: /
+  annotations:
+    kubernetes.io/ingressClassName: nginx
+    nginx.ingress.kubernetes.io/proxy-body-size: 10m
+livenessProbe:
+  path: /health
+  initialDelaySeconds: 15
+  timeoutSeconds: 15
+  scheme: HTTP
+  probeType: httpGet
+readinessProbe:
+  path: /ready
+  initialDelaySeconds: 5
+  timeoutSeconds: 3
+  scheme: HTTP
+  probeType: httpGet
+postgresql:
+  host: my-app-postgres
+  database: app
+  user: ""
+base:
+  path: /health
+`,
+		},
+		{
+			name:          "no image",
+			modify:        func(in *Input) { in.Image = "" },
+			expectedError: "the image is required",
+		},
+		{
+			name:          "no port",
+			modify:        func(in *Input) { in.Port = 0 },
+			expectedError: "0 is not a valid port",
+		},
+		{
+			name:          "no public URL and no base domain",
+			modify:        func(in *Input) { in.EnvironmentShort, in.KubeIngressBaseDomain = "", "" },
+			expectedError: "the public URL or the environment and the base domain are required",
+		},
+		{
+			name:          "invalid public URL",
+			modify:        func(in *Input) { in.PublicURL = "app.example.org" },
+			expectedError: `the public URL "app.example.org" is not valid`,
+		},
+		{
+			name:          "invalid layer",
+			layers:        []Layer{{Name: "values.yaml", Data: []byte("service: [")}},
+			expectedError: "values.yaml: yaml: line 1: did not find expected node content",
+		},
+		{
+			name:          "layer is not a mapping",
+			layers:        []Layer{{Name: "values.yaml", Data: []byte("\n- replicaCount: 2")}},
+			expectedError: "values.yaml: line 2: the values must be a mapping",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			in := input
+			if tc.modify != nil {
+				tc.modify(&in)
+			}
+			values, err := Generate(in, tc.layers...)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedValues, string(values))
+		})
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	variables := map[string]string{"tag": "v1", "empty": ""}
+	require.Equal(t, "v1 v1 v1x  $other ${other} $ $$ ${tag",
+		Substitute("$tag ${tag} ${tag}x $empty $other ${other} $ $$ ${tag", variables))
+}
+
+// TestGenerate_RendersThroughChart checks that the generated values are valid for the chart and end up in the
+// objects of the release.
+func TestGenerate_RendersThroughChart(t *testing.T) {
+	values, err := Generate(Input{
+		Image:                 "ghcr.io/sshoc/app:1a2b3c4",
+		AppName:               "app",
+		RepoURL:               "git://github.com/SSHOC/app.git",
+		ServiceID:             "12345",
+		AppRoot:               "/app",
+		Port:                  5000,
+		RefName:               "main",
+		EnvironmentShort:      "main",
+		KubeIngressBaseDomain: "example.com",
+		AdditionalHosts:       true,
+	}, Layer{Name: "caller.yaml", Data: []byte("replicaCount: 2\n")})
+	require.NoError(t, err)
+
+	ch, err := loader.Load(chartPath)
+	require.NoError(t, err)
+	vals, err := chartutil.ReadValues(values)
+	require.NoError(t, err)
+	renderValues, err := chartutil.ToRenderValues(ch, vals, chartutil.ReleaseOptions{
+		Name:      "production",
+		Namespace: "app",
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	require.NoError(t, err, "the values must be valid for the chart")
+	rendered, err := engine.Render(ch, renderValues)
+	require.NoError(t, err)
+
+	var deployment appsV1.Deployment
+	require.NoError(t, k8syaml.Unmarshal([]byte(rendered["auto-deploy-app/templates/deployment.yaml"]), &deployment))
+	require.Equal(t, int32(2), *deployment.Spec.Replicas)
+	require.Equal(t, "12345", deployment.Labels["ID"])
+	container := deployment.Spec.Template.Spec.Containers[0]
+	require.Equal(t, "ghcr.io/sshoc/app:1a2b3c4", container.Image)
+	require.Equal(t, int32(5000), container.Ports[0].ContainerPort)
+	require.Equal(t, "/app", container.LivenessProbe.HTTPGet.Path)
+	require.Equal(t, "/app", container.ReadinessProbe.HTTPGet.Path)
+
+	var ingress networkingV1.Ingress
+	require.NoError(t, k8syaml.Unmarshal([]byte(rendered["auto-deploy-app/templates/ingress.yaml"]), &ingress))
+	require.Equal(t, "/app", ingress.Annotations["nginx.ingress.kubernetes.io/app-root"])
+	var hosts []string
+	for _, rule := range ingress.Spec.Rules {
+		hosts = append(hosts, rule.Host)
+	}
+	require.Equal(t, []string{"main.example.com", "app-main.example.com"}, hosts)
+}
+
+// TestGenerate_ChartTestdata checks that the deploy workflow values the chart tests check against its schema are
+// what cmd/deploy-values prints, see .github/auto-deploy-app/CONTRIBUTING.md to regenerate them.
+func TestGenerate_ChartTestdata(t *testing.T) {
+	in := Input{
+		Image:                 "ghcr.io/sshoc/example-app:1a2b3c4",
+		AppName:               "example_app",
+		RepoURL:               "git://github.com/SSHOC/example_app.git",
+		ServiceID:             "1234",
+		AppRoot:               "/",
+		Port:                  5000,
+		RefName:               "feature-login",
+		EnvironmentShort:      "login",
+		KubeIngressBaseDomain: "example.org",
+	}
+	withAdditionalHost := in
+	withAdditionalHost.AppRoot = "/app"
+	withAdditionalHost.Port = 8080
+	withAdditionalHost.AdditionalHosts = true
+
+	for file, in := range map[string]Input{
+		"defaults.yaml":                     in,
+		"additional-host-and-app-root.yaml": withAdditionalHost,
+	} {
+		t.Run(file, func(t *testing.T) {
+			values, err := Generate(in)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(chartPath + "/test/testdata/deploy-values/" + file)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), string(values), "regenerate the file with cmd/deploy-values")
+		})
+	}
+}
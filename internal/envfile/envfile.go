@@ -0,0 +1,95 @@
+// Package envfile writes the environment of a build in the formats the build workflows read it in.
+package envfile
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Format is the format of an environment file.
+type Format string
+
+const (
+	// Dotenv writes KEY="value" lines, the value escaped like in a double quoted string of a .env file.
+	Dotenv Format = "dotenv"
+	// ShellExport writes export KEY='value' lines, for sh to source.
+	ShellExport Format = "shell-export"
+	// EnvFile writes KEY=value lines, as read by docker run --env-file and pack build --env-file. The values
+	// are taken literally, so they can't contain line breaks.
+	EnvFile Format = "env-file"
+)
+
+// Formats are the formats Write supports.
+var Formats = []Format{Dotenv, ShellExport, EnvFile}
+
+// shellNameRegexp matches the names sh accepts for a variable
+var shellNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dotenvEscaper escapes a value for a double quoted string of a .env file
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `\$`)
+
+// ParseFormat returns the format with the name.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, format := range Formats {
+		names[i] = string(format)
+	}
+	return "", fmt.Errorf("unknown format %q, use one of %s", name, strings.Join(names, ", "))
+}
+
+// Write writes the environment to w in the format, sorted by name.
+func Write(w io.Writer, env map[string]string, format Format) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		line, err := formatLine(name, env[name], format)
+		if err != nil {
+			return err
+		}
+		b.WriteString(line + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatLine(name string, value string, format Format) (string, error) {
+	switch format {
+	case Dotenv:
+		if !shellNameRegexp.MatchString(name) {
+			return "", fmt.Errorf("%q is not a valid name for %s", name, format)
+		}
+		return fmt.Sprintf(`%s="%s"`, name, dotenvEscaper.Replace(value)), nil
+	case ShellExport:
+		if !shellNameRegexp.MatchString(name) {
+			return "", fmt.Errorf("%q is not a valid name for %s", name, format)
+		}
+		return fmt.Sprintf("export %s=%s", name, shellQuote(value)), nil
+	case EnvFile:
+		if name == "" || strings.ContainsAny(name, "=# \t\r\n") {
+			return "", fmt.Errorf("%q is not a valid name for %s", name, format)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("the value of %s contains a line break, which %s can't hold", name, format)
+		}
+		return name + "=" + value, nil
+	}
+	return "", fmt.Errorf("unknown format %q", format)
+}
+
+// shellQuote quotes a value in single quotes, in which sh takes everything literally but single quotes.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
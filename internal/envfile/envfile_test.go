@@ -0,0 +1,135 @@
+package envfile
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// env has values that need quoting
+var env = map[string]string{
+	"PLAIN":     "value",
+	"NEWLINES":  "line 1\nline 2\r\n",
+	"QUOTES":    `it's "quoted"`,
+	"SHELL":     "$HOME `id` $(id) \\ ; & |",
+	"UNICODE":   "ünïcödé ✓ 日本",
+	"EMPTY":     "",
+	"EQUALS":    "a=b=c",
+	"lowercase": "x",
+}
+
+func TestWrite(t *testing.T) {
+	tcs := []struct {
+		name   string
+		format Format
+		env    map[string]string
+
+		expected      string
+		expectedError string
+	}{
+		{
+			name:   "dotenv",
+			format: Dotenv,
+			env:    env,
+			expected: `EMPTY=""
+EQUALS="a=b=c"
+NEWLINES="line 1\nline 2\r\n"
+PLAIN="value"
+QUOTES="it's \"quoted\""
+SHELL="\$HOME ` + "`id`" + ` \$(id) \\ ; & |"
+UNICODE="ünïcödé ✓ 日本"
+lowercase="x"
+`,
+		},
+		{
+			name:   "shell-export",
+			format: ShellExport,
+			env:    env,
+			expected: `export EMPTY=''
+export EQUALS='a=b=c'
+export NEWLINES='line 1
+line 2` + "\r" + `
+'
+export PLAIN='value'
+export QUOTES='it'\''s "quoted"'
+export SHELL='$HOME ` + "`id`" + ` $(id) \ ; & |'
+export UNICODE='ünïcödé ✓ 日本'
+export lowercase='x'
+`,
+		},
+		{
+			name:   "env-file",
+			format: EnvFile,
+			env:    map[string]string{"PLAIN": "value", "QUOTES": `it's "quoted"`, "UNICODE": "ünïcödé", "EMPTY": "", "django.key": "a=b"},
+			expected: `EMPTY=
+PLAIN=value
+QUOTES=it's "quoted"
+UNICODE=ünïcödé
+django.key=a=b
+`,
+		},
+		{
+			name:          "env-file with a line break",
+			format:        EnvFile,
+			env:           map[string]string{"KEY": "-----BEGIN KEY-----\nabc"},
+			expectedError: "the value of KEY contains a line break, which env-file can't hold",
+		},
+		{
+			name:          "name sh can't export",
+			format:        ShellExport,
+			env:           map[string]string{"django.key": "a"},
+			expectedError: `"django.key" is not a valid name for shell-export`,
+		},
+		{
+			name:          "unknown format",
+			format:        Format("yaml"),
+			env:           map[string]string{"A": "a"},
+			expectedError: `unknown format "yaml"`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			err := Write(&b, tc.env, tc.format)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, b.String())
+		})
+	}
+}
+
+// TestWrite_ShellExportRoundTrip checks that sh reads back the values that were written.
+func TestWrite_ShellExportRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to source the file with")
+	}
+	path := filepath.Join(t.TempDir(), "secrets.env")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, Write(f, env, ShellExport))
+	require.NoError(t, f.Close())
+
+	for name, value := range env {
+		out, err := exec.Command(sh, "-c", `. "$1" && printenv "$2"`, "sh", path, name).Output()
+		require.NoError(t, err)
+		require.Equal(t, value+"\n", string(out), name)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("shell-export")
+	require.NoError(t, err)
+	require.Equal(t, ShellExport, format)
+
+	_, err = ParseFormat("json")
+	require.EqualError(t, err, `unknown format "json", use one of dotenv, shell-export, env-file`)
+}
@@ -0,0 +1,216 @@
+// Package envslug derives the names the workflows use for a deployment from the git ref and APP_NAME.
+//
+// The rules, for a ref like refs/heads/feature/Login_Form and APP_NAME my_app-login-form:
+//
+//   - Environment is the GitHub environment: production for main, else review/<branch>, like review/feature/Login_Form.
+//   - Short is the slug of the environment, used in the public URL and in APP_NAME: prod for main, else the
+//     branch without a feat/ or feature/ prefix (or with _), slugged and cut to MaxShortLength, like login-form.
+//   - RefName is the slug of the whole branch cut to MaxShortLength, like feature-login-form.
+//   - ReleaseName is the slug of APP_NAME, like my-app-login-form. If it is longer than MaxShortLength it is cut
+//     and gets a hash of the whole slug appended, like the chart's suffixedname, so that long APP_NAMEs that
+//     only differ at the end still get different releases.
+//   - SecretName is the name of the Secret with the environment of the application, the slug of APP_NAME,
+//     cut and hashed like ReleaseName if it is longer than MaxLabelLength.
+//   - Namespace is KUBE_NAMESPACE if it is set, it must be a DNS label then, else APP_NAME, or its slug if
+//     APP_NAME is not a DNS label.
+//   - HostLabel is the first label of the additional host of the ingress, <APP_NAME>-<RefName> slugged.
+//
+// A slug is lower case, has a dash for every character that isn't a letter, a digit or a dash, and no dashes
+// at the start or at the end, so it is a valid DNS label (RFC 1123) once it is at most 63 characters long.
+package envslug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ProductionBranch is the branch deployed to the production environment.
+	ProductionBranch = "main"
+	// ProductionShort is the short slug of the production environment.
+	ProductionShort = "prod"
+	// MaxShortLength is the length the short slug, the ref name and the release name are cut to, so that
+	// APP_NAME-<short> and the names helm derives from the release still fit into a DNS label.
+	MaxShortLength = 24
+	// MaxLabelLength is the maximal length of a DNS label.
+	MaxLabelLength = 63
+	// MaxReleaseNameLength is the maximal length helm allows for the name of a release.
+	MaxReleaseNameLength = 53
+	// hashLength is the length of the hash appended to a cut name
+	hashLength = 8
+)
+
+var (
+	// labelRegexp matches a DNS label, without the length limit
+	labelRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	// invalidRegexp matches the characters that are replaced in a slug
+	invalidRegexp = regexp.MustCompile(`[^a-z0-9-]`)
+	// featurePrefixRegexp matches the prefix that is stripped from a branch for the short slug
+	featurePrefixRegexp = regexp.MustCompile(`^feat(ure)?[_/]`)
+)
+
+// Names are the names of a deployment, see the package documentation for the rules.
+type Names struct {
+	Environment string
+	Short       string
+	RefName     string
+	ReleaseName string // empty without APP_NAME
+	SecretName  string // empty without APP_NAME
+	Namespace   string // empty without APP_NAME and KUBE_NAMESPACE
+	HostLabel   string // empty without APP_NAME
+}
+
+// Compute returns the names for a ref, either a full ref like refs/heads/main or a branch name. The names
+// that depend on APP_NAME are left empty if appName is empty.
+func Compute(ref string, appName string, kubeNamespace string) (Names, error) {
+	branch := Branch(ref)
+	if branch == "" {
+		return Names{}, errors.New("the ref is required")
+	}
+
+	var names Names
+	var err error
+	names.Environment = Environment(branch)
+	if names.Short, err = Short(branch); err != nil {
+		return Names{}, err
+	}
+	if names.RefName, err = RefName(branch); err != nil {
+		return Names{}, err
+	}
+	if appName != "" {
+		if names.ReleaseName, err = ReleaseName(appName); err != nil {
+			return Names{}, err
+		}
+		if names.SecretName, err = SecretName(appName); err != nil {
+			return Names{}, err
+		}
+		if names.HostLabel, err = HostLabel(appName, names.RefName); err != nil {
+			return Names{}, err
+		}
+	}
+	if appName != "" || kubeNamespace != "" {
+		if names.Namespace, err = Namespace(kubeNamespace, appName); err != nil {
+			return Names{}, err
+		}
+	}
+	return names, nil
+}
+
+// Branch returns the branch or tag name of a ref like refs/heads/main, other values are returned as they are.
+func Branch(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+	return ref
+}
+
+// Environment returns the GitHub environment for a branch.
+func Environment(branch string) string {
+	if branch == ProductionBranch {
+		return "production"
+	}
+	return "review/" + branch
+}
+
+// Short returns the short slug of the environment for a branch.
+func Short(branch string) (string, error) {
+	if branch == ProductionBranch {
+		return ProductionShort, nil
+	}
+	return slug(featurePrefixRegexp.ReplaceAllString(strings.ToLower(branch), ""), MaxShortLength, "branch "+branch)
+}
+
+// RefName returns the slug of a branch.
+func RefName(branch string) (string, error) {
+	return slug(branch, MaxShortLength, "branch "+branch)
+}
+
+// ReleaseName returns the name of the helm release for APP_NAME.
+func ReleaseName(appName string) (string, error) {
+	name, err := hashedSlug(appName, MaxShortLength, "APP_NAME "+appName)
+	if err != nil {
+		return "", err
+	}
+	return name, ValidateReleaseName(name)
+}
+
+// LegacyReleaseName returns the name the deploy workflows gave the helm release for APP_NAME before ReleaseName:
+// lower cased, with dashes for underscores, cut to 24 characters and without dashes at the end. It is the same
+// for all APP_NAMEs that start with the same 24 characters.
+func LegacyReleaseName(appName string) string {
+	name := strings.ReplaceAll(strings.ToLower(appName), "_", "-")
+	if len(name) > 24 {
+		name = name[:24]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// SecretName returns the name of the Secret with the environment of the application for APP_NAME.
+func SecretName(appName string) (string, error) {
+	return hashedSlug(appName, MaxLabelLength, "APP_NAME "+appName)
+}
+
+// ValidateReleaseName returns an error if name is not a valid name for a helm release.
+func ValidateReleaseName(name string) error {
+	if len(name) > MaxReleaseNameLength {
+		return fmt.Errorf("the release name %q is longer than the %d characters helm allows", name, MaxReleaseNameLength)
+	}
+	return ValidateLabel(name)
+}
+
+// Namespace returns KUBE_NAMESPACE if it is set, else APP_NAME, the namespace the deploy workflows always used.
+// Only an APP_NAME that is not a DNS label, which kubectl refused as a namespace, is replaced by its slug.
+func Namespace(kubeNamespace string, appName string) (string, error) {
+	if kubeNamespace != "" {
+		if err := ValidateLabel(kubeNamespace); err != nil {
+			return "", fmt.Errorf("KUBE_NAMESPACE: %w", err)
+		}
+		return kubeNamespace, nil
+	}
+	if ValidateLabel(appName) == nil {
+		return appName, nil
+	}
+	return slug(appName, MaxLabelLength, "APP_NAME "+appName)
+}
+
+// HostLabel returns the first label of the additional host of the ingress.
+func HostLabel(appName string, refName string) (string, error) {
+	return slug(appName+"-"+refName, MaxLabelLength, "APP_NAME "+appName)
+}
+
+// ValidateLabel returns an error if s is not a DNS label.
+func ValidateLabel(s string) error {
+	if len(s) > MaxLabelLength || !labelRegexp.MatchString(s) {
+		return fmt.Errorf("%q is not a valid DNS label, it must be at most %d lower case letters, digits or dashes, starting and ending with a letter or digit", s, MaxLabelLength)
+	}
+	return nil
+}
+
+// hashedSlug returns the slug of s. If it is longer than maxLength it is cut and the first characters of its
+// sha256 sum are appended, the way the suffixedname template of the chart shortens names.
+func hashedSlug(s string, maxLength int, what string) (string, error) {
+	full := strings.Trim(invalidRegexp.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(full) <= maxLength {
+		return slug(full, maxLength, what)
+	}
+	sum := sha256.Sum256([]byte(full))
+	return slug(strings.TrimRight(full[:maxLength-hashLength-1], "-")+"-"+hex.EncodeToString(sum[:])[:hashLength], maxLength, what)
+}
+
+// slug returns the slug of s cut to maxLength, or an error naming what if nothing is left of it.
+func slug(s string, maxLength int, what string) (string, error) {
+	s = strings.Trim(invalidRegexp.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > maxLength {
+		s = strings.TrimRight(s[:maxLength], "-")
+	}
+	if s == "" {
+		return "", fmt.Errorf("%s has no letters or digits for a name", what)
+	}
+	return s, ValidateLabel(s)
+}
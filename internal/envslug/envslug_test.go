@@ -0,0 +1,277 @@
+package envslug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompute(t *testing.T) {
+	tcs := []struct {
+		name          string
+		ref           string
+		appName       string
+		kubeNamespace string
+
+		expectedNames Names
+		expectedError string
+	}{
+		{
+			name:    "production",
+			ref:     "refs/heads/main",
+			appName: "my_app-prod",
+			expectedNames: Names{
+				Environment: "production",
+				Short:       "prod",
+				RefName:     "main",
+				ReleaseName: "my-app-prod",
+				SecretName:  "my-app-prod",
+				Namespace:   "my-app-prod",
+				HostLabel:   "my-app-prod-main",
+			},
+		},
+		{
+			name:    "feature branch",
+			ref:     "refs/heads/feature/Login_Form",
+			appName: "my_app-login-form",
+			expectedNames: Names{
+				Environment: "review/feature/Login_Form",
+				Short:       "login-form",
+				RefName:     "feature-login-form",
+				ReleaseName: "my-app-login-form",
+				SecretName:  "my-app-login-form",
+				Namespace:   "my-app-login-form",
+				HostLabel:   "my-app-login-form-feature-login-form",
+			},
+		},
+		{
+			name: "feat_ prefix and a branch name without refs/heads/",
+			ref:  "Feat_search",
+			expectedNames: Names{
+				Environment: "review/Feat_search",
+				Short:       "search",
+				RefName:     "feat-search",
+			},
+		},
+		{
+			name: "prefix only at the start",
+			ref:  "fix/feature/x",
+			expectedNames: Names{
+				Environment: "review/fix/feature/x",
+				Short:       "fix-feature-x",
+				RefName:     "fix-feature-x",
+			},
+		},
+		{
+			name:    "long names are cut without a trailing dash, the release name gets a hash",
+			ref:     "refs/heads/feature/a-very-long-branch-name-for-the-review",
+			appName: "Some App With A Long Name-a-very-long-branch-name-f",
+			expectedNames: Names{
+				Environment: "review/feature/a-very-long-branch-name-for-the-review",
+				Short:       "a-very-long-branch-name",
+				RefName:     "feature-a-very-long-bran",
+				ReleaseName: "some-app-with-a-dcb8964c",
+				SecretName:  "some-app-with-a-long-name-a-very-long-branch-name-f",
+				Namespace:   "some-app-with-a-long-name-a-very-long-branch-name-f",
+				HostLabel:   "some-app-with-a-long-name-a-very-long-branch-name-f-feature-a-v",
+			},
+		},
+		{
+			name:          "KUBE_NAMESPACE",
+			ref:           "refs/heads/main",
+			appName:       "app-prod",
+			kubeNamespace: "apps",
+			expectedNames: Names{
+				Environment: "production",
+				Short:       "prod",
+				RefName:     "main",
+				ReleaseName: "app-prod",
+				SecretName:  "app-prod",
+				Namespace:   "apps",
+				HostLabel:   "app-prod-main",
+			},
+		},
+		{
+			name:          "tag",
+			ref:           "refs/tags/v1.2.0",
+			expectedNames: Names{Environment: "review/v1.2.0", Short: "v1-2-0", RefName: "v1-2-0"},
+		},
+		{
+			name:          "no ref",
+			expectedError: "the ref is required",
+		},
+		{
+			name:          "nothing left of the branch",
+			ref:           "refs/heads/feature/__",
+			expectedError: "branch feature/__ has no letters or digits for a name",
+		},
+		{
+			name:          "invalid KUBE_NAMESPACE",
+			ref:           "main",
+			appName:       "app",
+			kubeNamespace: "My_Apps",
+			expectedError: `KUBE_NAMESPACE: "My_Apps" is not a valid DNS label, it must be at most 63 lower case letters, digits or dashes, starting and ending with a letter or digit`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			names, err := Compute(tc.ref, tc.appName, tc.kubeNamespace)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+// TestCompute_ValidLabels checks that the slugs of odd branch names are DNS labels.
+func TestCompute_ValidLabels(t *testing.T) {
+	for _, ref := range []string{
+		"dependabot/npm_and_yarn/@babel/core-7.0.0",
+		"ÄÖÜ-umlauts",
+		"123/merge",
+		"feature/" + strings.Repeat("x-", 40),
+		"--dashes--",
+	} {
+		t.Run(ref, func(t *testing.T) {
+			names, err := Compute(ref, ref, "")
+			require.NoError(t, err)
+			for _, label := range []string{names.Short, names.RefName, names.ReleaseName, names.SecretName, names.Namespace, names.HostLabel} {
+				require.NoError(t, ValidateLabel(label))
+			}
+			require.LessOrEqual(t, len(names.Short), MaxShortLength)
+			require.LessOrEqual(t, len(names.ReleaseName), MaxShortLength)
+		})
+	}
+}
+
+func TestReleaseName(t *testing.T) {
+	tcs := []struct {
+		name    string
+		appName string
+
+		expectedReleaseName string
+		expectedSecretName  string
+		expectedError       string
+	}{
+		{
+			name:                "short",
+			appName:             "My_App-prod",
+			expectedReleaseName: "my-app-prod",
+			expectedSecretName:  "my-app-prod",
+		},
+		{
+			name:                "exactly 24 characters are not hashed",
+			appName:             "my-long-application-name",
+			expectedReleaseName: "my-long-application-name",
+			expectedSecretName:  "my-long-application-name",
+		},
+		{
+			name:                "long names that only differ at the end",
+			appName:             "my-long-application-name-review-a",
+			expectedReleaseName: "my-long-applica-48b217aa",
+			expectedSecretName:  "my-long-application-name-review-a",
+		},
+		{
+			name:                "long names that only differ at the end, the other one",
+			appName:             "my-long-application-name-review-b",
+			expectedReleaseName: "my-long-applica-e934db74",
+			expectedSecretName:  "my-long-application-name-review-b",
+		},
+		{
+			name:                "longer than a DNS label",
+			appName:             "a-very-long-app-name-that-is-longer-than-sixty-three-characters-for-a-secret",
+			expectedReleaseName: "a-very-long-app-6430000f",
+			expectedSecretName:  "a-very-long-app-name-that-is-longer-than-sixty-three-c-6430000f",
+		},
+		{
+			name:          "nothing left",
+			appName:       "___",
+			expectedError: "APP_NAME ___ has no letters or digits for a name",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			releaseName, err := ReleaseName(tc.appName)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				_, err = SecretName(tc.appName)
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedReleaseName, releaseName)
+			require.NoError(t, ValidateReleaseName(releaseName))
+
+			secretName, err := SecretName(tc.appName)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedSecretName, secretName)
+			require.NoError(t, ValidateLabel(secretName))
+		})
+	}
+}
+
+func TestLegacyReleaseName(t *testing.T) {
+	require.Equal(t, "my-app-prod", LegacyReleaseName("My_App-prod"))
+	require.Equal(t, "my-long-application-name", LegacyReleaseName("my-long-application-name-review-a"))
+	require.Equal(t, "my-long-application-name", LegacyReleaseName("my-long-application-name-review-b"))
+	require.Equal(t, "my-long-application", LegacyReleaseName("my-long-application-----x"))
+}
+
+func TestNamespace(t *testing.T) {
+	tcs := []struct {
+		name          string
+		kubeNamespace string
+		appName       string
+
+		expectedNamespace string
+		expectedError     string
+	}{
+		{
+			name:              "APP_NAME as before",
+			appName:           "my-app-prod",
+			expectedNamespace: "my-app-prod",
+		},
+		{
+			name:              "KUBE_NAMESPACE",
+			kubeNamespace:     "apps",
+			appName:           "my-app-prod",
+			expectedNamespace: "apps",
+		},
+		{
+			name:              "APP_NAME that is no DNS label",
+			appName:           "My_App-prod",
+			expectedNamespace: "my-app-prod",
+		},
+		{
+			name:          "nothing left",
+			appName:       "___",
+			expectedError: "APP_NAME ___ has no letters or digits for a name",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			namespace, err := Namespace(tc.kubeNamespace, tc.appName)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedNamespace, namespace)
+		})
+	}
+}
+
+func TestValidateReleaseName(t *testing.T) {
+	require.NoError(t, ValidateReleaseName(strings.Repeat("r", MaxReleaseNameLength)))
+	require.EqualError(t, ValidateReleaseName(strings.Repeat("r", 80)),
+		`the release name "`+strings.Repeat("r", 80)+`" is longer than the 53 characters helm allows`)
+	require.EqualError(t, ValidateReleaseName("My_Release"),
+		`"My_Release" is not a valid DNS label, it must be at most 63 lower case letters, digits or dashes, starting and ending with a letter or digit`)
+}
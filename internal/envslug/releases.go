@@ -0,0 +1,92 @@
+package envslug
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Release is a helm release as listed by helm list -o json.
+type Release struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Chart     string `json:"chart"`
+	Status    string `json:"status"`
+}
+
+// Collision is a release that may belong to another APP_NAME than the one it was checked for.
+type Collision struct {
+	Release Release
+	Reason  string
+}
+
+// ParseReleases parses the output of helm list -o json.
+func ParseReleases(data []byte) ([]Release, error) {
+	var releases []Release
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse the releases: %w", err)
+	}
+	return releases, nil
+}
+
+// DeployedReleaseName returns the name of the release of APP_NAME in the namespace: the release of
+// LegacyReleaseName if there is one and no release of ReleaseName, so that an application deployed before
+// ReleaseName is upgraded and not installed a second time next to the old release, else ReleaseName.
+func DeployedReleaseName(appName string, namespace string, releases []Release) (string, error) {
+	name, err := ReleaseName(appName)
+	if err != nil {
+		return "", err
+	}
+	legacyName := LegacyReleaseName(appName)
+	if legacyName != name && hasRelease(releases, namespace, legacyName) && !hasRelease(releases, namespace, name) {
+		return legacyName, nil
+	}
+	return name, nil
+}
+
+// Collisions returns the releases that collide with the release of APP_NAME in the namespace, or that did
+// before ReleaseName, see LegacyReleaseName. Releases in other namespaces are ignored, so are all releases if
+// namespace is empty and helm listed only one namespace.
+func Collisions(appName string, namespace string, releases []Release) ([]Collision, error) {
+	name, err := ReleaseName(appName)
+	if err != nil {
+		return nil, err
+	}
+	legacyName := LegacyReleaseName(appName)
+
+	var collisions []Collision
+	for _, release := range releases {
+		if !inNamespace(release, namespace) {
+			continue
+		}
+		switch {
+		case release.Name == legacyName && legacyName != name && !hasRelease(releases, release.Namespace, name):
+			collisions = append(collisions, Collision{release, fmt.Sprintf(
+				"it is upgraded as the release of %s, it has the old release name, which every APP_NAME starting "+
+					"with %q had: to deploy as %s, uninstall it and deploy again", appName, legacyName, name)})
+		case release.Name == legacyName && legacyName != name:
+			collisions = append(collisions, Collision{release, fmt.Sprintf(
+				"it has the old release name of %s, which every APP_NAME starting with %q had: "+
+					"uninstall it if it belongs to this application, it is deployed as %s now", appName, legacyName, name)})
+		case release.Name == name && name == legacyName && len(name) == MaxShortLength:
+			collisions = append(collisions, Collision{release, fmt.Sprintf(
+				"it is the release of %s, unless it is the release of a longer APP_NAME starting with %q "+
+					"from before release names got a hash: check gitlab.app in its values", appName, name)})
+		}
+	}
+	return collisions, nil
+}
+
+// hasRelease returns true if one of the releases in the namespace has the name.
+func hasRelease(releases []Release, namespace string, name string) bool {
+	for _, release := range releases {
+		if release.Name == name && inNamespace(release, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// inNamespace returns true if the release is in the namespace, or if either namespace is unknown.
+func inNamespace(release Release, namespace string) bool {
+	return namespace == "" || release.Namespace == "" || release.Namespace == namespace
+}
@@ -0,0 +1,170 @@
+package envslug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const helmList = `[
+  {"name":"my-long-application-name","namespace":"apps","revision":"3","updated":"2024-05-02 10:11:12.0 +0000 UTC","status":"deployed","chart":"auto-deploy-app-2.3.0","app_version":""},
+  {"name":"my-long-applica-48b217aa","namespace":"apps","revision":"1","updated":"2024-06-01 08:00:00.0 +0000 UTC","status":"deployed","chart":"auto-deploy-app-2.3.0","app_version":""},
+  {"name":"my-long-application-name","namespace":"other","revision":"1","updated":"2024-06-01 08:00:00.0 +0000 UTC","status":"failed","chart":"auto-deploy-app-2.3.0","app_version":""},
+  {"name":"my-app-prod","namespace":"apps","revision":"7","updated":"2024-06-01 08:00:00.0 +0000 UTC","status":"deployed","chart":"auto-deploy-app-2.3.0","app_version":""}
+]`
+
+func TestParseReleases(t *testing.T) {
+	releases, err := ParseReleases([]byte(helmList))
+	require.NoError(t, err)
+	require.Len(t, releases, 4)
+	require.Equal(t, Release{
+		Name:      "my-long-application-name",
+		Namespace: "apps",
+		Chart:     "auto-deploy-app-2.3.0",
+		Status:    "deployed",
+	}, releases[0])
+
+	_, err = ParseReleases([]byte(`{"name":"app"}`))
+	require.ErrorContains(t, err, "failed to parse the releases: ")
+}
+
+func TestCollisions(t *testing.T) {
+	releases, err := ParseReleases([]byte(helmList))
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name      string
+		appName   string
+		namespace string
+
+		expectedReleases []string
+		expectedReason   string
+		expectedError    string
+	}{
+		{
+			name:             "legacy release of a long APP_NAME",
+			appName:          "my-long-application-name-review-b",
+			namespace:        "apps",
+			expectedReleases: []string{"apps/my-long-application-name"},
+			expectedReason:   "it is upgraded as the release of my-long-application-name-review-b",
+		},
+		{
+			name:      "own release with a hash",
+			appName:   "my-long-application-name-review-a",
+			namespace: "apps",
+			// only the legacy release, the hashed one is the release of this APP_NAME
+			expectedReleases: []string{"apps/my-long-application-name"},
+			expectedReason:   "uninstall it if it belongs to this application",
+		},
+		{
+			name:             "release name of exactly 24 characters",
+			appName:          "my-long-application-name",
+			namespace:        "apps",
+			expectedReleases: []string{"apps/my-long-application-name"},
+		},
+		{
+			name:      "other namespace",
+			appName:   "my-long-application-name-review-b",
+			namespace: "staging",
+		},
+		{
+			name:      "short APP_NAME",
+			appName:   "my_app-prod",
+			namespace: "apps",
+		},
+		{
+			name:             "all namespaces without a namespace",
+			appName:          "my-long-application-name-review-b",
+			expectedReleases: []string{"apps/my-long-application-name", "other/my-long-application-name"},
+		},
+		{
+			name:          "invalid APP_NAME",
+			appName:       "--",
+			expectedError: "APP_NAME -- has no letters or digits for a name",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			collisions, err := Collisions(tc.appName, tc.namespace, releases)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, collision := range collisions {
+				require.NotEmpty(t, collision.Reason)
+				require.Contains(t, collision.Reason, tc.expectedReason)
+				names = append(names, collision.Release.Namespace+"/"+collision.Release.Name)
+			}
+			require.Equal(t, tc.expectedReleases, names)
+		})
+	}
+}
+
+func TestDeployedReleaseName(t *testing.T) {
+	releases, err := ParseReleases([]byte(helmList))
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name      string
+		appName   string
+		namespace string
+		releases  []Release
+
+		expected      string
+		expectedError string
+	}{
+		{
+			name:      "legacy release without a release with a hash",
+			appName:   "my-long-application-name-review-b",
+			namespace: "apps",
+			releases:  releases,
+			expected:  "my-long-application-name",
+		},
+		{
+			name:      "legacy release and a release with a hash",
+			appName:   "my-long-application-name-review-a",
+			namespace: "apps",
+			releases:  releases,
+			expected:  "my-long-applica-48b217aa",
+		},
+		{
+			name:      "legacy release in another namespace",
+			appName:   "my-long-application-name-review-b",
+			namespace: "staging",
+			releases:  releases,
+			expected:  "my-long-applica-e934db74",
+		},
+		{
+			name:     "first deployment",
+			appName:  "my-long-application-name-review-b",
+			expected: "my-long-applica-e934db74",
+		},
+		{
+			name:      "short APP_NAME",
+			appName:   "my_app-prod",
+			namespace: "apps",
+			releases:  releases,
+			expected:  "my-app-prod",
+		},
+		{
+			name:          "invalid APP_NAME",
+			appName:       "--",
+			expectedError: "APP_NAME -- has no letters or digits for a name",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			name, err := DeployedReleaseName(tc.appName, tc.namespace, tc.releases)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, name)
+		})
+	}
+}
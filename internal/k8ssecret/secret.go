@@ -0,0 +1,187 @@
+// Package k8ssecret builds the Secret the deploy workflows pass to the application: the K8S_SECRET_ and
+// LC_K8S_SECRET_ variables and secrets of the repository, SERVICE_ID and PUBLIC_URL, and the database settings.
+package k8ssecret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Prefix marks the variables and secrets that are passed to the application under the rest of their name.
+	Prefix = "K8S_SECRET_"
+	// LowerCasePrefix is like Prefix, but the name is lower cased. GitHub only allows upper case names.
+	LowerCasePrefix = "LC_K8S_SECRET_"
+)
+
+var (
+	// keyRegexp matches the valid keys of the data of a Secret
+	keyRegexp = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	// nameRegexp matches a DNS-1123 subdomain, the valid names of a Secret
+	nameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	// plainKeyRegexp matches the keys that can be written without quotes, unless they are one of yamlWords
+	plainKeyRegexp = regexp.MustCompile(`^[a-zA-Z_][-._a-zA-Z0-9]*$`)
+)
+
+// yamlWords are read as booleans or null by YAML 1.1, which kubectl uses
+var yamlWords = map[string]bool{"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true, "true": true, "false": true, "null": true}
+
+// Input is what the Secret is built from. Secrets and Vars are the `secrets` and `vars` contexts of the
+// workflow, see ParseContext, the other fields are the values the workflow computed, empty if unset.
+type Input struct {
+	Secrets map[string]string
+	Vars    map[string]string
+
+	ServiceID string // the SERVICE_ID input, takes precedence over vars.SERVICE_ID
+	PublicURL string // the PUBLIC_URL input, takes precedence over vars.PUBLIC_URL
+
+	PostgresHost     string
+	PostgresUser     string
+	PostgresPassword string
+	PostgresDB       string
+	DatabaseURL      string
+	DeployDatabase   string
+}
+
+// ParseContext decodes a `toJson(secrets)` or `toJson(vars)` context. An empty context is printed as null
+// by GitHub. Values that are not strings are kept as JSON.
+func ParseContext(data string) (map[string]string, error) {
+	var context map[string]interface{}
+	if strings.TrimSpace(data) != "" {
+		if err := json.Unmarshal([]byte(data), &context); err != nil {
+			return nil, fmt.Errorf("failed to parse the context: %w", err)
+		}
+	}
+
+	values := make(map[string]string, len(context))
+	for key, value := range context {
+		if s, ok := value.(string); ok {
+			values[key] = s
+			continue
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to a string: %w", key, err)
+		}
+		values[key] = string(b)
+	}
+	return values, nil
+}
+
+// Data returns the entries of the Secret, not yet base64 encoded. They are collected in this order, a later
+// entry replaces an earlier one with the same key:
+//  1. secrets and then vars named K8S_SECRET_<KEY>, as KEY
+//  2. SERVICE_ID and PUBLIC_URL, from the input or else from vars
+//  3. secrets and then vars named LC_K8S_SECRET_<KEY>, as the lower cased KEY
+//  4. POSTGRES_USER, POSTGRES_PASSWORD, POSTGRES_DB and POSTGRES_HOST if there is a POSTGRES_HOST
+//  5. DATABASE_URL and DEPLOY_DATABASE if they are set
+func Data(in Input) (map[string]string, error) {
+	data := make(map[string]string)
+	var errs []string
+	add := func(key string, value string, from string) {
+		if !keyRegexp.MatchString(key) {
+			errs = append(errs, fmt.Sprintf("%s: %q is not a valid key of a Secret", from, key))
+			return
+		}
+		data[key] = value
+	}
+	addPrefixed := func(context map[string]string, contextName string, prefix string, lowerCase bool) {
+		for _, name := range sortedKeys(context) {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			key := strings.TrimPrefix(name, prefix)
+			if lowerCase {
+				key = strings.ToLower(key)
+			}
+			add(key, context[name], contextName+"."+name)
+		}
+	}
+
+	addPrefixed(in.Secrets, "secrets", Prefix, false)
+	addPrefixed(in.Vars, "vars", Prefix, false)
+	if value := firstSet(in.ServiceID, in.Vars["SERVICE_ID"]); value != "" {
+		add("SERVICE_ID", value, "SERVICE_ID")
+	}
+	if value := firstSet(in.PublicURL, in.Vars["PUBLIC_URL"]); value != "" {
+		add("PUBLIC_URL", value, "PUBLIC_URL")
+	}
+	addPrefixed(in.Secrets, "secrets", LowerCasePrefix, true)
+	addPrefixed(in.Vars, "vars", LowerCasePrefix, true)
+
+	if in.PostgresHost != "" {
+		data["POSTGRES_USER"] = in.PostgresUser
+		data["POSTGRES_PASSWORD"] = in.PostgresPassword
+		data["POSTGRES_DB"] = in.PostgresDB
+		data["POSTGRES_HOST"] = in.PostgresHost
+	}
+	if in.DatabaseURL != "" {
+		data["DATABASE_URL"] = in.DatabaseURL
+	}
+	if in.DeployDatabase != "" {
+		data["DEPLOY_DATABASE"] = in.DeployDatabase
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid Secret keys:\n%s", strings.Join(errs, "\n"))
+	}
+	return data, nil
+}
+
+// Manifest returns the Secret with the data as a YAML manifest, the keys sorted.
+func Manifest(name string, data map[string]string) (string, error) {
+	if len(name) > 253 || !nameRegexp.MatchString(name) {
+		return "", fmt.Errorf("%q is not a valid name of a Secret, it must be a lower case DNS-1123 subdomain", name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: %s\ntype: Opaque\n", name)
+	if len(data) == 0 {
+		b.WriteString("data: {}\n")
+		return b.String(), nil
+	}
+	b.WriteString("data:\n")
+	for _, key := range sortedKeys(data) {
+		if !keyRegexp.MatchString(key) {
+			return "", fmt.Errorf("%q is not a valid key of a Secret", key)
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(data[key]))
+		if encoded == "" {
+			// an empty plain scalar is null
+			encoded = `""`
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", yamlKey(key), encoded)
+	}
+	return b.String(), nil
+}
+
+// yamlKey quotes the keys that YAML would read as something else than a string, like 1234, yes or null.
+func yamlKey(key string) string {
+	if !plainKeyRegexp.MatchString(key) || yamlWords[strings.ToLower(key)] {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
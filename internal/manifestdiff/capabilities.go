@@ -0,0 +1,70 @@
+package manifestdiff
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubeCapabilities returns the capabilities of the cluster of the kubeconfig, KUBECONFIG or ~/.kube/config and
+// its current context, the way helm upgrade finds them, so the chart renders the API versions the cluster serves.
+func KubeCapabilities() (*chartutil.Capabilities, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the kubeconfig: %w", err)
+	}
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the cluster: %w", err)
+	}
+	return Capabilities(client)
+}
+
+// Capabilities returns the Kubernetes version and the API versions a discovery client reports, both as
+// group/version and as group/version/Kind, like helm does.
+func Capabilities(client discovery.DiscoveryInterface) (*chartutil.Capabilities, error) {
+	version, err := client.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the version of the cluster: %w", err)
+	}
+	// an API service that is registered but unavailable fails its group only, helm ignores it as well
+	groups, resources, err := client.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to get the API versions of the cluster: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var apiVersions chartutil.VersionSet
+	add := func(apiVersion string) {
+		if !seen[apiVersion] {
+			seen[apiVersion] = true
+			apiVersions = append(apiVersions, apiVersion)
+		}
+	}
+	for _, group := range groups {
+		for _, groupVersion := range group.Versions {
+			add(groupVersion.GroupVersion)
+		}
+	}
+	for _, list := range resources {
+		for _, resource := range list.APIResources {
+			add(path.Join(list.GroupVersion, resource.Kind))
+		}
+	}
+	sort.Strings(apiVersions)
+
+	return &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{
+			Version: version.GitVersion,
+			Major:   version.Major,
+			Minor:   version.Minor,
+		},
+		APIVersions: apiVersions,
+		HelmVersion: chartutil.DefaultCapabilities.HelmVersion,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package manifestdiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	client := &fake.FakeDiscovery{
+		Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod"}, {Name: "pods/log", Kind: "Pod"}}},
+			{GroupVersion: "networking.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress"}}},
+		}},
+		FakedServerVersion: &version.Info{GitVersion: "v1.18.20", Major: "1", Minor: "18"},
+	}
+
+	capabilities, err := Capabilities(client)
+	require.NoError(t, err)
+	require.Equal(t, chartutil.KubeVersion{Version: "v1.18.20", Major: "1", Minor: "18"}, capabilities.KubeVersion)
+	require.Equal(t, chartutil.VersionSet{
+		"networking.k8s.io/v1beta1",
+		"networking.k8s.io/v1beta1/Ingress",
+		"v1",
+		"v1/Pod",
+	}, capabilities.APIVersions)
+	require.Equal(t, chartutil.DefaultCapabilities.HelmVersion, capabilities.HelmVersion)
+
+	// the chart renders the API versions the cluster serves
+	manifest, err := Render(RenderOptions{
+		Chart:        chartPath,
+		Set:          []string{"service.url=https://app.example.com"},
+		Release:      "production",
+		Capabilities: capabilities,
+	})
+	require.NoError(t, err)
+	objects, err := Parse(manifest)
+	require.NoError(t, err)
+	var ingresses []string
+	for _, object := range objects {
+		if object.Kind == "Ingress" {
+			ingresses = append(ingresses, object.Content["apiVersion"].(string))
+		}
+	}
+	require.Equal(t, []string{"networking.k8s.io/v1beta1"}, ingresses)
+}
@@ -0,0 +1,307 @@
+// Package manifestdiff compares the objects of a deployed helm release with the objects a chart renders now.
+//
+// The manifests are compared object by object, the objects matched by kind, namespace and name, and the
+// fields of an object by their path, so the order of keys doesn't matter. Lists of objects that all have a
+// name, like containers, env and ports, are matched by name instead of by position. The annotations helm adds
+// to the objects it manages, meta.helm.sh/*, are ignored.
+package manifestdiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// helmAnnotationPrefix is the prefix of the annotations helm manages
+const helmAnnotationPrefix = "meta.helm.sh/"
+
+// keyRegexp matches the keys that are written as .key in a path, others are written as ["key"]
+var keyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Object is a Kubernetes object of a manifest.
+type Object struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Content   map[string]interface{}
+}
+
+// String returns the kind and the name of the object, with the namespace if it has one.
+func (o Object) String() string {
+	if o.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+	}
+	return o.Kind + " " + o.Name
+}
+
+// key identifies an object in both manifests
+type key struct {
+	kind, namespace, name string
+}
+
+// Action is what happens to an object.
+type Action string
+
+const (
+	Added   Action = "added"
+	Changed Action = "changed"
+	Removed Action = "removed"
+)
+
+// Change is an object that is added, changed or removed.
+type Change struct {
+	Object Object // the rendered object, the deployed one if it is removed
+	Action Action
+	Fields []FieldChange // the changed fields if the object is changed
+}
+
+// FieldChange is a field that is added, changed or removed. Old is nil if the field is added, New if it is
+// removed.
+type FieldChange struct {
+	Path   string
+	Old    interface{}
+	New    interface{}
+	HasOld bool
+	HasNew bool
+	// Sensitive is set for the values of Secrets, which must not be shown.
+	Sensitive bool
+}
+
+// Parse returns the objects of a manifest with one or more YAML documents, like the output of helm get manifest.
+// The items of a List are returned as objects of their own, empty documents are skipped.
+func Parse(manifest string) ([]Object, error) {
+	var objects []Object
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for i := 1; ; i++ {
+		var content map[string]interface{}
+		err := decoder.Decode(&content)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document %d of the manifest: %w", i, err)
+		}
+		if content == nil {
+			continue
+		}
+		if content["kind"] == "List" {
+			items, _ := content["items"].([]interface{})
+			for j, item := range items {
+				itemContent, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("item %d of the List in document %d of the manifest is not an object", j+1, i)
+				}
+				object, err := newObject(itemContent)
+				if err != nil {
+					return nil, fmt.Errorf("item %d of the List in document %d of the manifest: %w", j+1, i, err)
+				}
+				objects = append(objects, object)
+			}
+			continue
+		}
+		object, err := newObject(content)
+		if err != nil {
+			return nil, fmt.Errorf("document %d of the manifest: %w", i, err)
+		}
+		objects = append(objects, object)
+	}
+}
+
+// newObject returns the object with the content, without the annotations helm manages.
+func newObject(content map[string]interface{}) (Object, error) {
+	kind, _ := content["kind"].(string)
+	metadata, _ := content["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	if kind == "" || name == "" {
+		return Object{}, errors.New("the object has no kind or no name")
+	}
+	if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+		for annotation := range annotations {
+			if strings.HasPrefix(annotation, helmAnnotationPrefix) {
+				delete(annotations, annotation)
+			}
+		}
+		if len(annotations) == 0 {
+			delete(metadata, "annotations")
+		}
+	}
+	return Object{Kind: kind, Namespace: namespace, Name: name, Content: content}, nil
+}
+
+// Diff returns the changes from the deployed to the rendered objects, sorted by kind and name. Unchanged objects
+// are left out.
+func Diff(deployed []Object, rendered []Object) []Change {
+	deployedByKey := make(map[key]Object, len(deployed))
+	for _, object := range deployed {
+		deployedByKey[key{object.Kind, object.Namespace, object.Name}] = object
+	}
+
+	var changes []Change
+	seen := make(map[key]bool, len(rendered))
+	for _, object := range rendered {
+		k := key{object.Kind, object.Namespace, object.Name}
+		seen[k] = true
+		old, ok := deployedByKey[k]
+		if !ok {
+			changes = append(changes, Change{Object: object, Action: Added})
+			continue
+		}
+		var fields []FieldChange
+		diffValues("", old.Content, object.Content, true, true, object.Kind == "Secret", &fields)
+		if len(fields) > 0 {
+			changes = append(changes, Change{Object: object, Action: Changed, Fields: fields})
+		}
+	}
+	for _, object := range deployed {
+		if !seen[key{object.Kind, object.Namespace, object.Name}] {
+			changes = append(changes, Change{Object: object, Action: Removed})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i].Object, changes[j].Object
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return changes
+}
+
+// Deletions returns the changes that remove an object of one of the kinds.
+func Deletions(changes []Change, kinds ...string) []Change {
+	var deletions []Change
+	for _, change := range changes {
+		if change.Action != Removed {
+			continue
+		}
+		for _, kind := range kinds {
+			if change.Object.Kind == kind {
+				deletions = append(deletions, change)
+				break
+			}
+		}
+	}
+	return deletions
+}
+
+// diffValues appends the differences between old and new at path to fields. The values of the data and
+// stringData of a Secret are sensitive.
+func diffValues(path string, old, new interface{}, hasOld, hasNew bool, secret bool, fields *[]FieldChange) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := make([]string, 0, len(oldMap)+len(newMap))
+		for k := range oldMap {
+			keys = append(keys, k)
+		}
+		for k := range newMap {
+			if _, ok := oldMap[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			oldValue, hasOldValue := oldMap[k]
+			newValue, hasNewValue := newMap[k]
+			diffValues(join(path, k), oldValue, newValue, hasOldValue, hasNewValue, secret, fields)
+		}
+		return
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		oldNames, oldNamed := names(oldList)
+		newNames, newNamed := names(newList)
+		if oldNamed && newNamed {
+			oldByName := make(map[string]interface{}, len(oldList))
+			for i, name := range oldNames {
+				oldByName[name] = oldList[i]
+			}
+			newByName := make(map[string]bool, len(newList))
+			for i, name := range newNames {
+				newByName[name] = true
+				oldValue, hasOldValue := oldByName[name]
+				diffValues(fmt.Sprintf("%s[name=%s]", path, name), oldValue, newList[i], hasOldValue, true, secret, fields)
+			}
+			for i, name := range oldNames {
+				if !newByName[name] {
+					diffValues(fmt.Sprintf("%s[name=%s]", path, name), oldList[i], nil, true, false, secret, fields)
+				}
+			}
+			return
+		}
+		for i := 0; i < len(oldList) || i < len(newList); i++ {
+			var oldValue, newValue interface{}
+			if i < len(oldList) {
+				oldValue = oldList[i]
+			}
+			if i < len(newList) {
+				newValue = newList[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), oldValue, newValue, i < len(oldList), i < len(newList), secret, fields)
+		}
+		return
+	}
+
+	if hasOld == hasNew && reflect.DeepEqual(old, new) {
+		return
+	}
+	*fields = append(*fields, FieldChange{
+		Path:      path,
+		Old:       old,
+		New:       new,
+		HasOld:    hasOld,
+		HasNew:    hasNew,
+		Sensitive: secret && (isField(path, "data") || isField(path, "stringData")),
+	})
+}
+
+// isField returns true if path is the top level field or one of its children.
+func isField(path string, field string) bool {
+	return path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(path, field+"[")
+}
+
+// names returns the names of the items of a list, if all items are objects with a distinct name.
+func names(list []interface{}) ([]string, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	names := make([]string, len(list))
+	seen := make(map[string]bool, len(list))
+	for i, item := range list {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := object["name"].(string)
+		if !ok || name == "" || seen[name] {
+			return nil, false
+		}
+		names[i] = name
+		seen[name] = true
+	}
+	return names, true
+}
+
+// join returns the path of the key k of the object at path.
+func join(path string, k string) string {
+	if !keyRegexp.MatchString(k) {
+		return fmt.Sprintf("%s[%q]", path, k)
+	}
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
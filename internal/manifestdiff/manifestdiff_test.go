@@ -0,0 +1,244 @@
+package manifestdiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const deployedManifest = `---
+# Source: auto-deploy-app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: production-auto-deploy
+  annotations:
+    meta.helm.sh/release-name: production
+    meta.helm.sh/release-namespace: app
+spec:
+  ports:
+  - port: 5000
+    name: web
+---
+# Source: auto-deploy-app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: production
+  labels:
+    app: production
+    track: stable
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: auto-deploy-app
+        image: ghcr.io/sshoc/app:1a2b3c4
+        env:
+        - name: DATABASE_URL
+          value: postgres://app:secret@db/app
+        - name: GITLAB_ENVIRONMENT_NAME
+          value: production
+---
+# Source: auto-deploy-app/templates/pvc.yaml
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: production-auto-deploy
+spec:
+  resources:
+    requests:
+      storage: 1Gi
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+data:
+  API_KEY: b2xk
+`
+
+const renderedManifest = `---
+# Source: auto-deploy-app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    track: stable
+    app: production
+  name: production
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - image: ghcr.io/sshoc/app:5d6e7f8
+        name: auto-deploy-app
+        env:
+        - name: GITLAB_ENVIRONMENT_NAME
+          value: production
+        - name: DATABASE_URL
+          value: postgres://app:secret@db/app
+        - name: SERVICE_ID
+          value: "12345"
+---
+# Source: auto-deploy-app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: production-auto-deploy
+spec:
+  ports:
+  - name: web
+    port: 5000
+---
+# Source: auto-deploy-app/templates/cronjob.yaml
+apiVersion: v1
+kind: List
+items:
+- apiVersion: batch/v1
+  kind: CronJob
+  metadata:
+    name: production-cleanup
+    namespace: app
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+data:
+  API_KEY: bmV3
+`
+
+func TestParse(t *testing.T) {
+	objects, err := Parse(deployedManifest)
+	require.NoError(t, err)
+	var names []string
+	for _, object := range objects {
+		names = append(names, object.String())
+	}
+	require.Equal(t, []string{
+		"Service production-auto-deploy",
+		"Deployment production",
+		"PersistentVolumeClaim production-auto-deploy",
+		"Secret app",
+	}, names)
+	require.NotContains(t, objects[0].Content["metadata"], "annotations", "the annotations of helm are ignored")
+
+	objects, err = Parse(renderedManifest)
+	require.NoError(t, err)
+	require.Equal(t, "CronJob app/production-cleanup", objects[2].String(), "the items of a List are objects")
+
+	objects, err = Parse("")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+
+	_, err = Parse("---\napiVersion: v1\nmetadata:\n  name: x\n")
+	require.EqualError(t, err, "document 1 of the manifest: the object has no kind or no name")
+
+	_, err = Parse("kind: [")
+	require.ErrorContains(t, err, "failed to parse document 1 of the manifest: ")
+}
+
+func TestDiff(t *testing.T) {
+	deployed, err := Parse(deployedManifest)
+	require.NoError(t, err)
+	rendered, err := Parse(renderedManifest)
+	require.NoError(t, err)
+
+	changes := Diff(deployed, rendered)
+	require.Equal(t, []Change{
+		{Object: rendered[2], Action: Added},
+		{Object: rendered[0], Action: Changed, Fields: []FieldChange{
+			{Path: "spec.replicas", Old: 1, New: 2, HasOld: true, HasNew: true},
+			{
+				Path:   "spec.template.spec.containers[name=auto-deploy-app].env[name=SERVICE_ID]",
+				New:    map[string]interface{}{"name": "SERVICE_ID", "value": "12345"},
+				HasNew: true,
+			},
+			{
+				Path:   "spec.template.spec.containers[name=auto-deploy-app].image",
+				Old:    "ghcr.io/sshoc/app:1a2b3c4",
+				New:    "ghcr.io/sshoc/app:5d6e7f8",
+				HasOld: true,
+				HasNew: true,
+			},
+		}},
+		{Object: deployed[2], Action: Removed},
+		{Object: rendered[3], Action: Changed, Fields: []FieldChange{
+			{Path: "data.API_KEY", Old: "b2xk", New: "bmV3", HasOld: true, HasNew: true, Sensitive: true},
+		}},
+	}, changes)
+
+	require.Empty(t, Diff(rendered, rendered))
+}
+
+func TestDiff_Lists(t *testing.T) {
+	tcs := []struct {
+		name     string
+		deployed string
+		rendered string
+
+		expectedFields []FieldChange
+	}{
+		{
+			name:     "by position without names",
+			deployed: "kind: Ingress\nmetadata: {name: app}\nspec:\n  tls:\n  - hosts: [a.example.com]\n",
+			rendered: "kind: Ingress\nmetadata: {name: app}\nspec:\n  tls:\n  - hosts: [a.example.com, b.example.com]\n",
+			expectedFields: []FieldChange{
+				{Path: "spec.tls[0].hosts[1]", New: "b.example.com", HasNew: true},
+			},
+		},
+		{
+			name:     "removed by name",
+			deployed: "kind: Service\nmetadata: {name: app}\nspec:\n  ports: [{name: web, port: 80}, {name: metrics, port: 9090}]\n",
+			rendered: "kind: Service\nmetadata: {name: app}\nspec:\n  ports: [{name: web, port: 80}]\n",
+			expectedFields: []FieldChange{
+				{Path: "spec.ports[name=metrics]", Old: map[string]interface{}{"name": "metrics", "port": 9090}, HasOld: true},
+			},
+		},
+		{
+			name:     "keys with dots",
+			deployed: "kind: Ingress\nmetadata:\n  name: app\n  annotations: {nginx.ingress.kubernetes.io/app-root: /}\n",
+			rendered: "kind: Ingress\nmetadata:\n  name: app\n  annotations: {nginx.ingress.kubernetes.io/app-root: /app}\n",
+			expectedFields: []FieldChange{
+				{Path: `metadata.annotations["nginx.ingress.kubernetes.io/app-root"]`, Old: "/", New: "/app", HasOld: true, HasNew: true},
+			},
+		},
+		{
+			name:     "null and missing",
+			deployed: "kind: ConfigMap\nmetadata: {name: app}\ndata:\n  a: null\n",
+			rendered: "kind: ConfigMap\nmetadata: {name: app}\ndata: {}\n",
+			expectedFields: []FieldChange{
+				{Path: "data.a", HasOld: true},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			deployed, err := Parse(tc.deployed)
+			require.NoError(t, err)
+			rendered, err := Parse(tc.rendered)
+			require.NoError(t, err)
+			changes := Diff(deployed, rendered)
+			require.Len(t, changes, 1)
+			require.Equal(t, tc.expectedFields, changes[0].Fields)
+		})
+	}
+}
+
+func TestDeletions(t *testing.T) {
+	deployed, err := Parse(deployedManifest)
+	require.NoError(t, err)
+
+	changes := Diff(deployed, nil)
+	require.Len(t, changes, 4)
+	deletions := Deletions(changes, "PersistentVolumeClaim", "Secret")
+	require.Len(t, deletions, 2)
+	require.Equal(t, "PersistentVolumeClaim production-auto-deploy", deletions[0].Object.String())
+	require.Equal(t, "Secret app", deletions[1].Object.String())
+
+	require.Empty(t, Deletions(Diff(nil, deployed), "PersistentVolumeClaim", "Secret"))
+}
@@ -0,0 +1,109 @@
+package manifestdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// hidden replaces the sensitive values of a change
+	hidden = "(hidden)"
+	// redacted replaces the redacted strings in the values of a change
+	redacted = "(redacted)"
+)
+
+// Markdown writes the changes to the release as Markdown for the summary of a job: a table of the objects and
+// the changed fields of every changed object. The values of Secrets are hidden, and every occurrence of one of
+// the redact strings in a value is replaced, so that a password that ends up in an environment variable of a
+// Deployment doesn't show up in the summary.
+func Markdown(w io.Writer, release string, changes []Change, redact []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Changes to the release `%s`\n\n", release)
+	if len(changes) == 0 {
+		b.WriteString("No objects change.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("|Object|Change|\n|-|-|\n")
+	for _, change := range changes {
+		action := string(change.Action)
+		if change.Action == Removed {
+			action = "**" + action + "**"
+		}
+		fmt.Fprintf(&b, "|%s `%s`|%s|\n", change.Object.Kind, objectName(change.Object), action)
+	}
+
+	for _, change := range changes {
+		if change.Action != Changed {
+			continue
+		}
+		var lines []string
+		for _, field := range change.Fields {
+			if field.HasOld {
+				lines = append(lines, fmt.Sprintf("- %s: %s", field.Path, formatValue(field.Old, field.Sensitive, redact)))
+			}
+			if field.HasNew {
+				lines = append(lines, fmt.Sprintf("+ %s: %s", field.Path, formatValue(field.New, field.Sensitive, redact)))
+			}
+		}
+		diff := strings.Join(lines, "\n")
+		fence := "```"
+		for strings.Contains(diff, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "\n<details><summary>%s</summary>\n\n%sdiff\n%s\n%s\n\n</details>\n", change.Object, fence, diff, fence)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// objectName returns the name of the object, with the namespace if it has one.
+func objectName(o Object) string {
+	if o.Namespace != "" {
+		return o.Namespace + "/" + o.Name
+	}
+	return o.Name
+}
+
+// formatValue returns the value as JSON on a single line, hidden if it is sensitive.
+func formatValue(value interface{}, sensitive bool, redact []string) string {
+	if sensitive {
+		return hidden
+	}
+	data, err := json.Marshal(redactValue(value, redact))
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// redactValue returns a copy of value with the redact strings replaced in all strings.
+func redactValue(value interface{}, redact []string) interface{} {
+	switch value := value.(type) {
+	case string:
+		for _, s := range redact {
+			if s != "" {
+				value = strings.ReplaceAll(value, s, redacted)
+			}
+		}
+		return value
+	case map[string]interface{}:
+		redactedMap := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			redactedMap[k] = redactValue(v, redact)
+		}
+		return redactedMap
+	case []interface{}:
+		redactedList := make([]interface{}, len(value))
+		for i, v := range value {
+			redactedList[i] = redactValue(v, redact)
+		}
+		return redactedList
+	default:
+		return value
+	}
+}
@@ -0,0 +1,80 @@
+package manifestdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdown(t *testing.T) {
+	deployed, err := Parse(deployedManifest + `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scripts
+data:
+  run.md: "~~~"
+`)
+	require.NoError(t, err)
+	rendered, err := Parse(strings.ReplaceAll(renderedManifest, "app:secret@", "app:n3w-s3cret@") + `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scripts
+data:
+  run.md: "` + "```sh" + `"
+`)
+	require.NoError(t, err)
+
+	var b strings.Builder
+	require.NoError(t, Markdown(&b, "production", Diff(deployed, rendered), []string{"secret", "n3w-s3cret", ""}))
+	require.Equal(t, "### Changes to the release `production`"+`
+
+|Object|Change|
+|-|-|
+|ConfigMap `+"`scripts`"+`|changed|
+|CronJob `+"`app/production-cleanup`"+`|added|
+|Deployment `+"`production`"+`|changed|
+|PersistentVolumeClaim `+"`production-auto-deploy`"+`|**removed**|
+|Secret `+"`app`"+`|changed|
+
+<details><summary>ConfigMap scripts</summary>
+
+`+"````"+`diff
+- data["run.md"]: "~~~"
++ data["run.md"]: "`+"```"+`sh"
+`+"````"+`
+
+</details>
+
+<details><summary>Deployment production</summary>
+
+`+"```"+`diff
+- spec.replicas: 1
++ spec.replicas: 2
+- spec.template.spec.containers[name=auto-deploy-app].env[name=DATABASE_URL].value: "postgres://app:(redacted)@db/app"
++ spec.template.spec.containers[name=auto-deploy-app].env[name=DATABASE_URL].value: "postgres://app:(redacted)@db/app"
++ spec.template.spec.containers[name=auto-deploy-app].env[name=SERVICE_ID]: {"name":"SERVICE_ID","value":"12345"}
+- spec.template.spec.containers[name=auto-deploy-app].image: "ghcr.io/sshoc/app:1a2b3c4"
++ spec.template.spec.containers[name=auto-deploy-app].image: "ghcr.io/sshoc/app:5d6e7f8"
+`+"```"+`
+
+</details>
+
+<details><summary>Secret app</summary>
+
+`+"```"+`diff
+- data.API_KEY: (hidden)
++ data.API_KEY: (hidden)
+`+"```"+`
+
+</details>
+`, b.String())
+}
+
+func TestMarkdown_NoChanges(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, Markdown(&b, "production", nil, nil))
+	require.Equal(t, "### Changes to the release `production`\n\nNo objects change.\n", b.String())
+}
@@ -0,0 +1,136 @@
+package manifestdiff
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+// RenderOptions are the chart, the values and the release to render the manifest of.
+type RenderOptions struct {
+	Chart       string   // the path of the chart
+	ValuesFiles []string // merged in order, the later ones winning, like helm --values
+	Set         []string // applied after the values files, like helm --set
+	SetString   []string // applied after Set, like helm --set-string
+	Release     string
+	Namespace   string
+	Upgrade     bool // whether the release is installed already
+	// Capabilities are those of the cluster, see KubeCapabilities, helm's defaults if nil
+	Capabilities *chartutil.Capabilities
+}
+
+// AddHelmArgs adds the --values, -f, --set and --set-string arguments of helm upgrade, like those of
+// HELM_UPGRADE_EXTRA_ARGS, to the options, in the order helm applies them. It returns the arguments it doesn't
+// know, they don't change the rendered manifest or are not supported.
+func (opts *RenderOptions) AddHelmArgs(args []string) (ignored []string, err error) {
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		var list *[]string
+		switch name {
+		case "-f", "--values":
+			list = &opts.ValuesFiles
+		case "--set":
+			list = &opts.Set
+		case "--set-string":
+			list = &opts.SetString
+		default:
+			ignored = append(ignored, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("helm argument %s has no value", name)
+			}
+			i++
+			value = args[i]
+		}
+		*list = append(*list, value)
+	}
+	return ignored, nil
+}
+
+// Render returns the manifest helm upgrade --install would apply, in the format of helm get manifest. Hooks and
+// NOTES.txt are left out like helm does.
+func Render(opts RenderOptions) (string, error) {
+	ch, err := loader.Load(opts.Chart)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the chart: %w", err)
+	}
+
+	vals := map[string]interface{}{}
+	for _, file := range opts.ValuesFiles {
+		fileVals, err := chartutil.ReadValuesFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		vals = mergeMaps(vals, fileVals)
+	}
+	for _, set := range opts.Set {
+		if err := strvals.ParseInto(set, vals); err != nil {
+			return "", fmt.Errorf("failed to parse --set %s: %w", strings.SplitN(set, "=", 2)[0], err)
+		}
+	}
+	for _, set := range opts.SetString {
+		if err := strvals.ParseIntoString(set, vals); err != nil {
+			return "", fmt.Errorf("failed to parse --set-string %s: %w", strings.SplitN(set, "=", 2)[0], err)
+		}
+	}
+	capabilities := opts.Capabilities
+	if capabilities == nil {
+		capabilities = chartutil.DefaultCapabilities
+	}
+
+	renderValues, err := chartutil.ToRenderValues(ch, vals, chartutil.ReleaseOptions{
+		Name:      opts.Release,
+		Namespace: opts.Namespace,
+		Revision:  1,
+		IsInstall: !opts.Upgrade,
+		IsUpgrade: opts.Upgrade,
+	}, capabilities)
+	if err != nil {
+		return "", fmt.Errorf("the values are not valid for the chart: %w", err)
+	}
+	files, err := engine.Render(ch, renderValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to render the chart: %w", err)
+	}
+	for name := range files {
+		if path.Base(name) == "NOTES.txt" {
+			delete(files, name)
+		}
+	}
+
+	_, manifests, err := releaseutil.SortManifests(files, capabilities.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, manifest := range manifests {
+		fmt.Fprintf(&b, "---\n# Source: %s\n%s\n", manifest.Name, manifest.Content)
+	}
+	return b.String(), nil
+}
+
+// mergeMaps returns a copy of a with b merged into it, the way helm merges values files.
+func mergeMaps(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		if v, ok := v.(map[string]interface{}); ok {
+			if bv, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeMaps(bv, v)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
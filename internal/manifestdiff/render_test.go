@@ -0,0 +1,99 @@
+package manifestdiff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const chartPath = "../../.github/auto-deploy-app"
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	deployValues := filepath.Join(dir, "auto-deploy-values.yaml")
+	require.NoError(t, os.WriteFile(deployValues, []byte(`image:
+  repository: ghcr.io/sshoc/app
+  tag: 1a2b3c4
+replicaCount: 1
+application:
+  migrateCommand: ./migrate
+service:
+  url: https://app.example.com
+`), 0o600))
+	callerValues := filepath.Join(dir, "caller.yaml")
+	require.NoError(t, os.WriteFile(callerValues, []byte("replicaCount: 2\n"), 0o600))
+
+	opts := RenderOptions{
+		Chart:       chartPath,
+		ValuesFiles: []string{deployValues},
+		Release:     "production",
+		Namespace:   "app",
+	}
+	manifest, err := Render(opts)
+	require.NoError(t, err)
+	deployed, err := Parse(manifest)
+	require.NoError(t, err)
+	for _, object := range deployed {
+		require.NotEqual(t, "Job", object.Kind, "hooks are not part of the manifest")
+	}
+
+	opts.ValuesFiles = append(opts.ValuesFiles, callerValues)
+	opts.Set = []string{"image.tag=5d6e7f8"}
+	opts.Upgrade = true
+	manifest, err = Render(opts)
+	require.NoError(t, err)
+	rendered, err := Parse(manifest)
+	require.NoError(t, err)
+
+	changes := Diff(deployed, rendered)
+	require.Len(t, changes, 1)
+	require.Equal(t, "Deployment production", changes[0].Object.String())
+	var paths []string
+	for _, field := range changes[0].Fields {
+		paths = append(paths, field.Path)
+	}
+	require.Equal(t, []string{
+		"spec.replicas",
+		"spec.template.spec.containers[name=auto-deploy-app].image",
+	}, paths)
+
+	opts.Set = []string{"image.tag"}
+	_, err = Render(opts)
+	require.EqualError(t, err, "failed to parse --set image.tag: key \"tag\" has no value")
+}
+
+func TestAddHelmArgs(t *testing.T) {
+	opts := RenderOptions{ValuesFiles: []string{"auto-deploy-values.yaml"}, Set: []string{"image.tag=1a2b3c4"}}
+	ignored, err := opts.AddHelmArgs([]string{
+		"--set", "replicaCount=2", "--timeout", "10m", "-f", "caller.yaml", "--set-string=service.port=5000",
+		"--values=more.yaml", "--debug", "--set=worker.enabled=true",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"--timeout", "10m", "--debug"}, ignored)
+	require.Equal(t, RenderOptions{
+		ValuesFiles: []string{"auto-deploy-values.yaml", "caller.yaml", "more.yaml"},
+		Set:         []string{"image.tag=1a2b3c4", "replicaCount=2", "worker.enabled=true"},
+		SetString:   []string{"service.port=5000"},
+	}, opts)
+
+	_, err = opts.AddHelmArgs([]string{"--set"})
+	require.EqualError(t, err, "helm argument --set has no value")
+}
+
+func TestMergeMaps(t *testing.T) {
+	require.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": 3},
+		"d": "e",
+		"f": []interface{}{2},
+	}, mergeMaps(map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": 2},
+		"d": map[string]interface{}{"x": 1},
+		"f": []interface{}{1},
+	}, map[string]interface{}{
+		"a": map[string]interface{}{"c": 3},
+		"d": "e",
+		"f": []interface{}{2},
+	}))
+}
@@ -0,0 +1,51 @@
+package retention
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Markdown writes the plan as Markdown for the summary of a job: a table of the versions to delete, and the
+// kept versions in a collapsed table.
+func Markdown(w io.Writer, plan Plan) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Container package `%s`\n\n", plan.Package)
+	total := len(plan.Delete) + len(plan.Keep)
+	if len(plan.Delete) == 0 {
+		fmt.Fprintf(&b, "Keeps all %d versions.\n", total)
+	} else {
+		fmt.Fprintf(&b, "Deletes %d of %d versions.\n\n", len(plan.Delete), total)
+		writeTable(&b, plan.Delete)
+	}
+	if len(plan.Keep) > 0 {
+		fmt.Fprintf(&b, "\n<details><summary>Keeps %d versions</summary>\n\n", len(plan.Keep))
+		writeTable(&b, plan.Keep)
+		b.WriteString("\n</details>\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTable writes the decisions as a Markdown table.
+func writeTable(b *strings.Builder, decisions []Decision) {
+	b.WriteString("|Version|Tags|Created|Reason|\n|-|-|-|-|\n")
+	for _, d := range decisions {
+		tags := make([]string, len(d.Tags))
+		for i, tag := range d.Tags {
+			tags[i] = "`" + tag + "`"
+		}
+		fmt.Fprintf(b, "|%d `%s`|%s|%s|%s|\n", d.ID, shortDigest(d.Digest), strings.Join(tags, " "),
+			d.Created.UTC().Format(time.RFC3339), d.Reason)
+	}
+}
+
+// shortDigest returns the first 12 hex digits of a digest, like docker images does.
+func shortDigest(digest string) string {
+	hex := strings.TrimPrefix(digest, "sha256:")
+	if len(hex) > 12 {
+		return hex[:12]
+	}
+	return hex
+}
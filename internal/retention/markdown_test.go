@@ -0,0 +1,54 @@
+package retention
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdown(t *testing.T) {
+	created := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+	tcs := []struct {
+		name     string
+		plan     Plan
+		expected string
+	}{
+		{
+			name: "deletions",
+			plan: Plan{
+				Package: "app",
+				Delete: []Decision{{
+					Version: Version{ID: 2, Digest: "sha256:0123456789abcdef", Tags: []string{"sha-a", "dev"}, Created: created},
+					Reason:  "older than 30d",
+				}},
+				Keep: []Decision{{
+					Version: Version{ID: 3, Digest: "sha256:fedcba9876543210", Tags: []string{}, Created: created},
+					Reason:  "deployed",
+				}},
+			},
+			expected: "### Container package `app`\n\n" +
+				"Deletes 1 of 2 versions.\n\n" +
+				"|Version|Tags|Created|Reason|\n|-|-|-|-|\n" +
+				"|2 `0123456789ab`|`sha-a` `dev`|2026-02-01T10:00:00Z|older than 30d|\n" +
+				"\n<details><summary>Keeps 1 versions</summary>\n\n" +
+				"|Version|Tags|Created|Reason|\n|-|-|-|-|\n" +
+				"|3 `fedcba987654`||2026-02-01T10:00:00Z|deployed|\n" +
+				"\n</details>\n",
+		},
+		{
+			name:     "nothing to delete",
+			plan:     Plan{Package: "tests/app", Delete: []Decision{}, Keep: []Decision{}},
+			expected: "### Container package `tests/app`\n\nKeeps all 0 versions.\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			require.NoError(t, Markdown(&b, tc.plan))
+			require.Equal(t, tc.expected, b.String())
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package retention
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is what the plan keeps, in a YAML file:
+//
+//	minAge: 30d
+//	untaggedMinAge: 7d
+//	keepTags: [latest]
+//	keepSemver: true
+//	keepLatest:
+//	  - prefix: main
+//	    count: 3
+//	testsMaxAge: 30d
+type Policy struct {
+	// MinAge is the age after which a version no rule keeps is deleted.
+	MinAge Age `yaml:"minAge"`
+	// UntaggedMinAge is the age after which an untagged version is deleted, MinAge if 0.
+	UntaggedMinAge Age `yaml:"untaggedMinAge"`
+	// KeepTags are tags that are always kept, like latest.
+	KeepTags []string `yaml:"keepTags"`
+	// KeepSemver keeps the versions with a semantic version tag, like 1.2.3 or 1.2.
+	KeepSemver bool `yaml:"keepSemver"`
+	// KeepLatest keeps the newest versions with a tag that starts with a prefix.
+	KeepLatest []KeepLatest `yaml:"keepLatest"`
+	// TestsMaxAge is the age after which a version of a tests/ package is deleted, whatever else the policy
+	// keeps. The other rules apply to the tests/ packages if 0.
+	TestsMaxAge Age `yaml:"testsMaxAge"`
+}
+
+// KeepLatest keeps the Count newest versions with a tag that starts with Prefix.
+type KeepLatest struct {
+	Prefix string `yaml:"prefix"`
+	Count  int    `yaml:"count"`
+}
+
+// keepsTag returns true if the policy always keeps the tag.
+func (p Policy) keepsTag(tag string) bool {
+	for _, keep := range p.KeepTags {
+		if tag == keep {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePolicy parses and checks a policy file.
+func ParsePolicy(data []byte) (Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return Policy{}, fmt.Errorf("parse policy: %w", err)
+	}
+	if p.MinAge <= 0 {
+		return Policy{}, errors.New("the policy has no minAge")
+	}
+	for _, rule := range p.KeepLatest {
+		if rule.Prefix == "" || rule.Count <= 0 {
+			return Policy{}, fmt.Errorf("keepLatest needs a prefix and a count above 0, not %q and %d", rule.Prefix, rule.Count)
+		}
+	}
+	return p, nil
+}
+
+// Age is a duration in days, like 30d, or a Go duration, like 12h.
+type Age time.Duration
+
+// ParseAge parses an age.
+func ParseAge(s string) (Age, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a valid age", s)
+		}
+		return Age(time.Duration(n) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a valid age", s)
+	}
+	return Age(d), nil
+}
+
+// String returns the age in days if it is whole days.
+func (a Age) String() string {
+	d := time.Duration(a)
+	if d != 0 && d%(24*time.Hour) == 0 {
+		return strconv.FormatInt(int64(d/(24*time.Hour)), 10) + "d"
+	}
+	return d.String()
+}
+
+// UnmarshalYAML parses an age.
+func (a *Age) UnmarshalYAML(value *yaml.Node) error {
+	age, err := ParseAge(value.Value)
+	if err != nil {
+		return err
+	}
+	*a = age
+	return nil
+}
@@ -0,0 +1,78 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tcs := []struct {
+		name   string
+		policy string
+
+		expectedPolicy Policy
+		expectedError  string
+	}{
+		{
+			name: "all rules",
+			policy: `
+minAge: 30d
+untaggedMinAge: 12h
+keepTags: [latest]
+keepSemver: true
+keepLatest:
+  - prefix: main
+    count: 3
+testsMaxAge: 14d
+`,
+			expectedPolicy: Policy{
+				MinAge:         Age(30 * 24 * time.Hour),
+				UntaggedMinAge: Age(12 * time.Hour),
+				KeepTags:       []string{"latest"},
+				KeepSemver:     true,
+				KeepLatest:     []KeepLatest{{Prefix: "main", Count: 3}},
+				TestsMaxAge:    Age(14 * 24 * time.Hour),
+			},
+		},
+		{
+			name:          "no minimum age",
+			policy:        "keepSemver: true\n",
+			expectedError: "the policy has no minAge",
+		},
+		{
+			name:          "unknown rule",
+			policy:        "minAge: 30d\nkeepLast: 3\n",
+			expectedError: "parse policy: yaml: unmarshal errors:\n  line 2: field keepLast not found in type retention.Policy",
+		},
+		{
+			name:          "invalid age",
+			policy:        "minAge: a month\n",
+			expectedError: `parse policy: "a month" is not a valid age`,
+		},
+		{
+			name:          "keepLatest without a count",
+			policy:        "minAge: 30d\nkeepLatest:\n  - prefix: main\n",
+			expectedError: `keepLatest needs a prefix and a count above 0, not "main" and 0`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := ParsePolicy([]byte(tc.policy))
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPolicy, policy)
+		})
+	}
+}
+
+func TestAge_String(t *testing.T) {
+	require.Equal(t, "7d", Age(7*24*time.Hour).String())
+	require.Equal(t, "36h0m0s", Age(36*time.Hour).String())
+	require.Equal(t, "0s", Age(0).String())
+}
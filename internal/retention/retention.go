@@ -0,0 +1,258 @@
+// Package retention plans which versions of a container package in the GitHub registry to delete.
+//
+// A version is kept if it is deployed, has a tag the policy keeps, has a semantic version tag, or is one of the
+// newest versions with a tag prefix. Versions of the tests/ packages the herokuish tests push are deleted once
+// they are older than the policy allows, everything else once no rule keeps it and it is older than the
+// minimum age. The plan lists every version with the reason it is kept or deleted, so it can be reviewed
+// before anything is deleted.
+package retention
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestsPrefix is the prefix of the packages the herokuish tests push.
+const TestsPrefix = "tests/"
+
+// platformWindow is how long before or after a kept tagged version an untagged version is kept. Multi-platform
+// builds push the image of every platform and the attestations as untagged versions next to the tagged index,
+// deleting them breaks the tagged image.
+const platformWindow = 5 * time.Minute
+
+// semverTag matches a tag of docker/metadata-action type=semver, like 1.2.3 or 1.2, after a prefix ending
+// with - or _.
+var semverTag = regexp.MustCompile(`(^|[-_])v?\d+\.\d+(\.\d+)?(-[0-9A-Za-z.-]+)?$`)
+
+// Version is a version of a container package.
+type Version struct {
+	ID      int64     `json:"id"`
+	Digest  string    `json:"digest"`
+	Tags    []string  `json:"tags"`
+	Created time.Time `json:"created"`
+}
+
+// apiVersion is a version as the GitHub API lists it.
+type apiVersion struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Metadata  struct {
+		Container struct {
+			Tags []string `json:"tags"`
+		} `json:"container"`
+	} `json:"metadata"`
+}
+
+// ParseVersions parses the versions of a package as gh api --paginate prints them: one JSON array per page.
+func ParseVersions(r io.Reader) ([]Version, error) {
+	dec := json.NewDecoder(r)
+	var versions []Version
+	for {
+		var page []apiVersion
+		err := dec.Decode(&page)
+		if errors.Is(err, io.EOF) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse versions: %w", err)
+		}
+		for _, v := range page {
+			tags := v.Metadata.Container.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			versions = append(versions, Version{ID: v.ID, Digest: v.Name, Tags: tags, Created: v.CreatedAt})
+		}
+	}
+}
+
+// Image is a reference to a container image, like ghcr.io/org/app:main or ghcr.io/org/app@sha256:...
+type Image struct {
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImage parses an image reference.
+func ParseImage(ref string) Image {
+	var image Image
+	ref, image.Digest, _ = strings.Cut(ref, "@")
+	// a : after the last / separates the tag, before it the port of the registry
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref, image.Tag = ref[:i], ref[i+1:]
+	}
+	image.Repository = strings.ToLower(ref)
+	return image
+}
+
+// ParseImages parses the image references separated by white space, like kubectl get -o jsonpath='{..image}'
+// prints them.
+func ParseImages(r io.Reader) ([]Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var images []Image
+	for _, ref := range strings.Fields(string(data)) {
+		images = append(images, ParseImage(ref))
+	}
+	return images, nil
+}
+
+// isPackage returns true if the image is in the package, the last path elements of its repository.
+func (i Image) isPackage(pkg string) bool {
+	pkg = strings.ToLower(pkg)
+	return i.Repository == pkg || strings.HasSuffix(i.Repository, "/"+pkg)
+}
+
+// Decision is a version of the plan and the reason it is kept or deleted.
+type Decision struct {
+	Version
+	Reason string `json:"reason"`
+}
+
+// Plan is what to keep and what to delete of a package.
+type Plan struct {
+	Package string     `json:"package"`
+	Delete  []Decision `json:"delete"`
+	Keep    []Decision `json:"keep"`
+}
+
+// NewPlan plans which versions of the package to delete by the policy at the time now. The images are the
+// deployed images, of any package. The versions of the plan are sorted newest first.
+func NewPlan(pkg string, versions []Version, policy Policy, deployed []Image, now time.Time) Plan {
+	versions = append([]Version(nil), versions...)
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Created.After(versions[j].Created)
+	})
+
+	deployedTags := map[string]bool{}
+	deployedDigests := map[string]bool{}
+	for _, image := range deployed {
+		if !image.isPackage(pkg) {
+			continue
+		}
+		if image.Tag != "" {
+			deployedTags[image.Tag] = true
+		}
+		if image.Digest != "" {
+			deployedDigests[image.Digest] = true
+		}
+	}
+	newest := map[int64]string{}
+	for _, rule := range policy.KeepLatest {
+		count := 0
+		for _, v := range versions {
+			if count == rule.Count {
+				break
+			}
+			if hasPrefix(v.Tags, rule.Prefix) {
+				count++
+				if _, ok := newest[v.ID]; !ok {
+					newest[v.ID] = fmt.Sprintf("one of the %d newest versions tagged %s*", rule.Count, rule.Prefix)
+				}
+			}
+		}
+	}
+
+	reasons := make([]string, len(versions))
+	keep := make([]bool, len(versions))
+	var keptTagged []time.Time
+	for i, v := range versions {
+		age := now.Sub(v.Created)
+		reasons[i], keep[i] = func() (string, bool) {
+			if tag := firstTag(v.Tags, func(tag string) bool { return deployedTags[tag] }); tag != "" {
+				return "tag " + tag + " is deployed", true
+			}
+			if deployedDigests[v.Digest] {
+				return "deployed", true
+			}
+			if strings.HasPrefix(pkg, TestsPrefix) && policy.TestsMaxAge > 0 {
+				if age > time.Duration(policy.TestsMaxAge) {
+					return "test image older than " + policy.TestsMaxAge.String(), false
+				}
+				return "test image newer than " + policy.TestsMaxAge.String(), true
+			}
+			if tag := firstTag(v.Tags, policy.keepsTag); tag != "" {
+				return "tag " + tag + " is kept", true
+			}
+			if tag := firstTag(v.Tags, semverTag.MatchString); policy.KeepSemver && tag != "" {
+				return "semantic version " + tag, true
+			}
+			if reason, ok := newest[v.ID]; ok {
+				return reason, true
+			}
+			if len(v.Tags) == 0 {
+				return "", false // decided below
+			}
+			if age > time.Duration(policy.MinAge) {
+				return "older than " + policy.MinAge.String(), false
+			}
+			return "newer than " + policy.MinAge.String(), true
+		}()
+		if keep[i] && len(v.Tags) > 0 {
+			keptTagged = append(keptTagged, v.Created)
+		}
+	}
+
+	untaggedMinAge := policy.UntaggedMinAge
+	if untaggedMinAge == 0 {
+		untaggedMinAge = policy.MinAge
+	}
+	for i, v := range versions {
+		if reasons[i] != "" {
+			continue
+		}
+		switch {
+		case nearby(v.Created, keptTagged):
+			reasons[i], keep[i] = "untagged, probably a platform image of a kept tag", true
+		case now.Sub(v.Created) > time.Duration(untaggedMinAge):
+			reasons[i], keep[i] = "untagged, older than "+untaggedMinAge.String(), false
+		default:
+			reasons[i], keep[i] = "untagged, newer than "+untaggedMinAge.String(), true
+		}
+	}
+
+	plan := Plan{Package: pkg, Delete: []Decision{}, Keep: []Decision{}}
+	for i, v := range versions {
+		d := Decision{Version: v, Reason: reasons[i]}
+		if keep[i] {
+			plan.Keep = append(plan.Keep, d)
+		} else {
+			plan.Delete = append(plan.Delete, d)
+		}
+	}
+	return plan
+}
+
+// firstTag returns the first of the tags that matches, or "".
+func firstTag(tags []string, match func(string) bool) string {
+	for _, tag := range tags {
+		if match(tag) {
+			return tag
+		}
+	}
+	return ""
+}
+
+// hasPrefix returns true if one of the tags starts with the prefix.
+func hasPrefix(tags []string, prefix string) bool {
+	return firstTag(tags, func(tag string) bool { return strings.HasPrefix(tag, prefix) }) != ""
+}
+
+// nearby returns true if t is within the platformWindow of one of the times.
+func nearby(t time.Time, times []time.Time) bool {
+	for _, other := range times {
+		if d := t.Sub(other); d > -platformWindow && d < platformWindow {
+			return true
+		}
+	}
+	return false
+}
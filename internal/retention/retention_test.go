@@ -0,0 +1,162 @@
+package retention
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func mustReadFixtures(t *testing.T, versionsFile string) ([]Version, Policy, []Image) {
+	t.Helper()
+	f, err := os.Open("testdata/" + versionsFile)
+	require.NoError(t, err)
+	defer f.Close()
+	versions, err := ParseVersions(f)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile("testdata/policy.yaml")
+	require.NoError(t, err)
+	policy, err := ParsePolicy(data)
+	require.NoError(t, err)
+
+	deployedFile, err := os.Open("testdata/deployed.txt")
+	require.NoError(t, err)
+	defer deployedFile.Close()
+	deployed, err := ParseImages(deployedFile)
+	require.NoError(t, err)
+	return versions, policy, deployed
+}
+
+func TestParseVersions(t *testing.T) {
+	versions, _, _ := mustReadFixtures(t, "app-versions.json")
+	require.Len(t, versions, 11, "both pages are read")
+	require.Equal(t, Version{
+		ID:      10,
+		Digest:  "sha256:000000000000000000000000000000000000000000000000000000000000000a",
+		Tags:    []string{"main", "sha-aaaa111"},
+		Created: time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC),
+	}, versions[1])
+	require.Equal(t, []string{}, versions[0].Tags)
+
+	_, err := ParseVersions(strings.NewReader(`{"message": "Not Found"}`))
+	require.Error(t, err)
+}
+
+func TestParseImage(t *testing.T) {
+	tcs := []struct {
+		name     string
+		ref      string
+		expected Image
+	}{
+		{
+			name:     "tag",
+			ref:      "ghcr.io/SSHOC/app:main",
+			expected: Image{Repository: "ghcr.io/sshoc/app", Tag: "main"},
+		},
+		{
+			name:     "digest",
+			ref:      "ghcr.io/sshoc/app@sha256:abc",
+			expected: Image{Repository: "ghcr.io/sshoc/app", Digest: "sha256:abc"},
+		},
+		{
+			name:     "tag and digest",
+			ref:      "ghcr.io/sshoc/app:main@sha256:abc",
+			expected: Image{Repository: "ghcr.io/sshoc/app", Tag: "main", Digest: "sha256:abc"},
+		},
+		{
+			name:     "registry with a port",
+			ref:      "registry.example.com:5000/app",
+			expected: Image{Repository: "registry.example.com:5000/app"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ParseImage(tc.ref))
+		})
+	}
+}
+
+// decisions returns the ids and reasons of the decisions.
+func decisions(ds []Decision) map[int64]string {
+	m := map[int64]string{}
+	for _, d := range ds {
+		m[d.ID] = d.Reason
+	}
+	return m
+}
+
+func TestNewPlan(t *testing.T) {
+	versions, policy, deployed := mustReadFixtures(t, "app-versions.json")
+	plan := NewPlan("app", versions, policy, deployed, now)
+
+	require.Equal(t, "app", plan.Package)
+	require.Equal(t, map[int64]string{
+		8: "older than 30d",
+		2: "untagged, older than 7d",
+	}, decisions(plan.Delete))
+	require.Equal(t, map[int64]string{
+		11: "untagged, probably a platform image of a kept tag",
+		10: "one of the 1 newest versions tagged main*",
+		3:  "untagged, newer than 7d",
+		9:  "one of the 2 newest versions tagged sha-*",
+		1:  "newer than 30d",
+		7:  "semantic version 1.2.0",
+		6:  "tag latest is kept",
+		5:  "tag sha-dddd444 is deployed",
+		4:  "deployed",
+	}, decisions(plan.Keep))
+	require.Equal(t, int64(11), plan.Keep[0].ID, "newest first")
+}
+
+func TestNewPlan_Tests(t *testing.T) {
+	versions, policy, deployed := mustReadFixtures(t, "tests-app-versions.json")
+	plan := NewPlan("tests/app", versions, policy, deployed, now)
+
+	require.Equal(t, map[int64]string{
+		21: "test image older than 14d",
+		20: "test image older than 14d",
+	}, decisions(plan.Delete))
+	require.Equal(t, map[int64]string{
+		22: "test image newer than 14d",
+	}, decisions(plan.Keep))
+}
+
+func TestNewPlan_RepositoryPolicy(t *testing.T) {
+	f, err := os.Open("testdata/built-app-versions.json")
+	require.NoError(t, err)
+	defer f.Close()
+	versions, err := ParseVersions(f)
+	require.NoError(t, err)
+	data, err := os.ReadFile("../../.github/container-retention.yaml")
+	require.NoError(t, err)
+	policy, err := ParsePolicy(data)
+	require.NoError(t, err)
+
+	// the tags of the build workflows: the branch or pr-N, the short commit sha, latest and the semantic versions
+	plan := NewPlan("app", versions, policy, nil, now)
+
+	require.Equal(t, map[int64]string{
+		32: "older than 30d",
+		29: "older than 30d",
+		28: "untagged, older than 7d",
+	}, decisions(plan.Delete))
+	require.Equal(t, map[int64]string{
+		36: "untagged, probably a platform image of a kept tag",
+		35: "tag main is kept",
+		34: "newer than 30d",
+		33: "newer than 30d",
+		31: "tag latest is kept",
+		30: "semantic version 1.1.0",
+	}, decisions(plan.Keep))
+}
+
+func TestNewPlan_Empty(t *testing.T) {
+	plan := NewPlan("app", nil, Policy{MinAge: Age(time.Hour)}, nil, now)
+	require.Equal(t, Plan{Package: "app", Delete: []Decision{}, Keep: []Decision{}}, plan)
+}
@@ -0,0 +1,263 @@
+package rollout
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// Problem is a kind of problem the diagnosis recognizes.
+type Problem string
+
+// The problems the diagnosis recognizes.
+const (
+	CrashLoop       Problem = "Crash loop"
+	ImagePull       Problem = "Image pull"
+	ContainerConfig Problem = "Container config"
+	FailingProbe    Problem = "Failing probe"
+	MigrationFailed Problem = "Failed db-migrate hook"
+	JobFailed       Problem = "Failed job"
+	Unschedulable   Problem = "Unschedulable"
+	NotCreated      Problem = "Pods not created"
+)
+
+// migrateSuffix is the suffix of the name of the db-migrate hook Job
+const migrateSuffix = "-db-migrate"
+
+var (
+	// fieldPathRegexp matches the field path of an event about a container
+	fieldPathRegexp = regexp.MustCompile(`^spec\.(?:initContainers|containers)\{(.+)\}$`)
+	// probeURLRegexp matches the URL in the message of a failed HTTP probe
+	probeURLRegexp = regexp.MustCompile(`Get "([^"]+)"`)
+)
+
+// Finding is a problem of an object, and of a container of a pod.
+type Finding struct {
+	Problem   Problem
+	Kind      string
+	Name      string
+	Container string
+	Message   string
+	// Logs are the last lines of the log of the container in a crash loop or of the failed job, if they were
+	// collected.
+	Logs []string
+}
+
+// Object returns the kind and name of the object and the container, like Pod app-5d9c/web.
+func (f Finding) Object() string {
+	if f.Container != "" {
+		return f.Kind + " " + f.Name + "/" + f.Container
+	}
+	return f.Kind + " " + f.Name
+}
+
+// diagnosis collects the findings, once for every problem of an object.
+type diagnosis struct {
+	objs     Objects
+	logs     Logs
+	findings []Finding
+	seen     map[string]bool
+}
+
+func (d *diagnosis) add(f Finding, key string) {
+	key = strings.Join([]string{string(f.Problem), f.Kind, f.Name, f.Container, key}, "|")
+	if d.seen[key] {
+		return
+	}
+	d.seen[key] = true
+	if f.Problem == CrashLoop && f.Kind == "Pod" {
+		f.Logs = d.logs.Get(f.Name, f.Container)
+	}
+	d.findings = append(d.findings, f)
+}
+
+// Diagnose returns the problems of the jobs, pods and events, and the logs of the containers that failed. The
+// status of a job or pod is more precise than its events and is looked at first.
+func Diagnose(objs Objects, logs Logs) []Finding {
+	d := &diagnosis{objs: objs, logs: logs, seen: map[string]bool{}}
+	for _, job := range objs.Jobs {
+		d.job(job)
+	}
+	for _, pod := range objs.Pods {
+		d.pod(pod)
+	}
+	for _, event := range objs.Events {
+		if event.Type == coreV1.EventTypeWarning {
+			d.event(event)
+		}
+	}
+	return d.findings
+}
+
+// jobProblem returns the problem of a failed job.
+func jobProblem(name string) Problem {
+	if strings.HasSuffix(name, migrateSuffix) {
+		return MigrationFailed
+	}
+	return JobFailed
+}
+
+func (d *diagnosis) job(job batchV1.Job) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type != batchV1.JobFailed || condition.Status != coreV1.ConditionTrue {
+			continue
+		}
+		f := Finding{
+			Problem: jobProblem(job.Name),
+			Kind:    "Job",
+			Name:    job.Name,
+			Message: fmt.Sprintf("%s: %s", condition.Reason, condition.Message),
+		}
+		// the log of the last pod of the job that was collected
+		for _, pod := range d.objs.Pods {
+			if pod.Labels["job-name"] != job.Name {
+				continue
+			}
+			for _, container := range pod.Spec.Containers {
+				if lines := d.logs.Get(pod.Name, container.Name); lines != nil {
+					f.Logs = lines
+				}
+			}
+		}
+		d.add(f, "")
+	}
+}
+
+func (d *diagnosis) pod(pod coreV1.Pod) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == coreV1.PodScheduled && condition.Status == coreV1.ConditionFalse && condition.Reason == coreV1.PodReasonUnschedulable {
+			d.add(Finding{Problem: Unschedulable, Kind: "Pod", Name: pod.Name, Message: condition.Message}, "")
+		}
+	}
+	statuses := append(append([]coreV1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		waiting := status.State.Waiting
+		if waiting == nil {
+			continue
+		}
+		f := Finding{Kind: "Pod", Name: pod.Name, Container: status.Name, Message: waiting.Message}
+		switch waiting.Reason {
+		case "CrashLoopBackOff":
+			f.Problem = CrashLoop
+			f.Message = fmt.Sprintf("restarted %d times", status.RestartCount)
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				f.Message += fmt.Sprintf(", last exit code %d (%s)", terminated.ExitCode, terminated.Reason)
+			}
+		case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+			f.Problem = ImagePull
+			if f.Message == "" {
+				f.Message = fmt.Sprintf("%s: %s", waiting.Reason, status.Image)
+			}
+		case "CreateContainerConfigError", "CreateContainerError":
+			f.Problem = ContainerConfig
+		default:
+			continue
+		}
+		d.add(f, "")
+	}
+}
+
+func (d *diagnosis) event(event coreV1.Event) {
+	involved := event.InvolvedObject
+	f := Finding{Kind: involved.Kind, Name: involved.Name, Message: event.Message}
+	if m := fieldPathRegexp.FindStringSubmatch(involved.FieldPath); m != nil {
+		f.Container = m[1]
+	}
+	key := ""
+	switch event.Reason {
+	case "Unhealthy":
+		// like Readiness probe failed: HTTP probe failed with statuscode: 503
+		probe, detail, _ := strings.Cut(event.Message, " probe failed: ")
+		f.Problem = FailingProbe
+		f.Message = probe + " probe"
+		if target := d.probeTarget(involved.Name, f.Container, probe, event.Message); target != "" {
+			f.Message += " " + target
+		}
+		f.Message += " failed" + times(event) + ": " + detail
+		key = probe
+	case "BackOff":
+		switch {
+		case strings.Contains(event.Message, "pulling image"):
+			f.Problem = ImagePull
+		case strings.Contains(event.Message, "restarting failed container"):
+			f.Problem = CrashLoop
+			f.Message = "restarted repeatedly" + times(event)
+		default:
+			return
+		}
+	case "Failed":
+		// like Failed to pull image ..., or Error: ImagePullBackOff
+		if strings.Contains(strings.ToLower(event.Message), "image") {
+			f.Problem = ImagePull
+		} else {
+			f.Problem = ContainerConfig
+		}
+	case "FailedScheduling":
+		f.Problem = Unschedulable
+	case "FailedCreate":
+		f.Problem = NotCreated
+	case "BackoffLimitExceeded", "DeadlineExceeded":
+		f.Problem = jobProblem(involved.Name)
+		f.Message = fmt.Sprintf("%s: %s", event.Reason, event.Message)
+	default:
+		return
+	}
+	d.add(f, key)
+}
+
+// times returns how often the event happened, if more than once.
+func times(event coreV1.Event) string {
+	count := event.Count
+	if event.Series != nil && event.Series.Count > count {
+		count = event.Series.Count
+	}
+	if count > 1 {
+		return fmt.Sprintf(" %d times", count)
+	}
+	return ""
+}
+
+// probeTarget describes what the probe of the container checks, like GET /healthz on port 5000. The spec of
+// the pod is used if it still exists, else the URL of the message of an HTTP probe.
+func (d *diagnosis) probeTarget(podName, container, probe, message string) string {
+	for _, pod := range d.objs.Pods {
+		if pod.Name != podName {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			if c.Name != container {
+				continue
+			}
+			var p *coreV1.Probe
+			switch probe {
+			case "Liveness":
+				p = c.LivenessProbe
+			case "Readiness":
+				p = c.ReadinessProbe
+			case "Startup":
+				p = c.StartupProbe
+			}
+			if p == nil {
+				break
+			}
+			switch {
+			case p.HTTPGet != nil:
+				return fmt.Sprintf("GET %s on port %s", p.HTTPGet.Path, p.HTTPGet.Port.String())
+			case p.TCPSocket != nil:
+				return fmt.Sprintf("TCP port %s", p.TCPSocket.Port.String())
+			case p.Exec != nil:
+				return fmt.Sprintf("command %q", strings.Join(p.Exec.Command, " "))
+			}
+		}
+	}
+	if m := probeURLRegexp.FindStringSubmatch(message); m != nil {
+		if u, err := url.Parse(m[1]); err == nil {
+			return fmt.Sprintf("GET %s on port %s", u.Path, u.Port())
+		}
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package rollout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiagnose(t *testing.T) {
+	objs, logs := mustReadFixtures(t)
+	findings := Diagnose(objs.Select(release, map[string]string{}), logs)
+
+	require.Equal(t, []Finding{
+		{
+			Problem: MigrationFailed,
+			Kind:    "Job",
+			Name:    "review-feature-x-db-migrate",
+			Message: "BackoffLimitExceeded: Job has reached the specified backoff limit",
+			Logs: []string{
+				"Operations to perform:",
+				"",
+				`django.db.utils.OperationalError: FATAL:  password authentication failed for user "app"`,
+			},
+		},
+		{
+			Problem:   CrashLoop,
+			Kind:      "Pod",
+			Name:      "review-feature-x-6b7d9c-abcde",
+			Container: "auto-deploy-app",
+			Message:   "restarted 5 times, last exit code 1 (Error)",
+			Logs: []string{
+				"Starting server on port 5000",
+				"Error: connect ECONNREFUSED 10.43.0.5:5432",
+			},
+		},
+		{
+			Problem:   ImagePull,
+			Kind:      "Pod",
+			Name:      "review-feature-x-6b7d9c-fghij",
+			Container: "auto-deploy-app",
+			Message:   `Back-off pulling image "ghcr.io/sshoc/app:sha-1234567"`,
+		},
+		{
+			Problem: Unschedulable,
+			Kind:    "Pod",
+			Name:    "review-feature-x-6b7d9c-klmno",
+			Message: "0/3 nodes are available: 3 Insufficient memory. preemption: 0/3 nodes are available: 3 No preemption victims found for incoming pod.",
+		},
+		{
+			Problem:   FailingProbe,
+			Kind:      "Pod",
+			Name:      "review-feature-x-6b7d9c-abcde",
+			Container: "auto-deploy-app",
+			Message:   "Readiness probe GET /healthz on port 5000 failed 12 times: HTTP probe failed with statuscode: 503",
+		},
+		{
+			Problem:   FailingProbe,
+			Kind:      "Pod",
+			Name:      "review-feature-x-6b7d9c-zzzzz",
+			Container: "auto-deploy-app",
+			Message:   `Liveness probe GET /healthz on port 5000 failed 3 times: Get "http://10.42.0.17:5000/healthz": dial tcp 10.42.0.17:5000: connect: connection refused`,
+		},
+	}, findings)
+}
+
+func TestDiagnose_Events(t *testing.T) {
+	tcs := []struct {
+		name     string
+		event    coreV1.Event
+		expected []Finding
+	}{
+		{
+			name: "image pull of a deleted pod",
+			event: coreV1.Event{
+				Type:           coreV1.EventTypeWarning,
+				Reason:         "Failed",
+				Message:        `Failed to pull image "app:sha-1": not found`,
+				InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: "app-1", FieldPath: "spec.initContainers{wait-for-dependencies}"},
+			},
+			expected: []Finding{{Problem: ImagePull, Kind: "Pod", Name: "app-1", Container: "wait-for-dependencies", Message: `Failed to pull image "app:sha-1": not found`}},
+		},
+		{
+			name: "missing secret",
+			event: coreV1.Event{
+				Type:           coreV1.EventTypeWarning,
+				Reason:         "Failed",
+				Message:        `Error: secret "app-secret" not found`,
+				InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: "app-1", FieldPath: "spec.containers{web}"},
+			},
+			expected: []Finding{{Problem: ContainerConfig, Kind: "Pod", Name: "app-1", Container: "web", Message: `Error: secret "app-secret" not found`}},
+		},
+		{
+			name: "quota",
+			event: coreV1.Event{
+				Type:           coreV1.EventTypeWarning,
+				Reason:         "FailedCreate",
+				Message:        `Error creating: pods "app-1" is forbidden: exceeded quota: compute`,
+				InvolvedObject: coreV1.ObjectReference{Kind: "ReplicaSet", Name: "app-5d9c"},
+			},
+			expected: []Finding{{Problem: NotCreated, Kind: "ReplicaSet", Name: "app-5d9c", Message: `Error creating: pods "app-1" is forbidden: exceeded quota: compute`}},
+		},
+		{
+			name: "crash loop of a deleted pod",
+			event: coreV1.Event{
+				Type:           coreV1.EventTypeWarning,
+				Reason:         "BackOff",
+				Message:        "Back-off restarting failed container web in pod app-1",
+				Series:         &coreV1.EventSeries{Count: 14, LastObservedTime: metaV1.MicroTime{}},
+				InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: "app-1", FieldPath: "spec.containers{web}"},
+			},
+			expected: []Finding{{Problem: CrashLoop, Kind: "Pod", Name: "app-1", Container: "web", Message: "restarted repeatedly 14 times"}},
+		},
+		{
+			name: "normal event",
+			event: coreV1.Event{
+				Type:           coreV1.EventTypeNormal,
+				Reason:         "Pulled",
+				InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: "app-1"},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := Diagnose(Objects{Events: []coreV1.Event{tc.event}}, Logs{})
+			require.Equal(t, tc.expected, findings)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package rollout
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// maxWarnings is how many warning events are listed if no problem was found.
+const maxWarnings = 10
+
+// Markdown writes the findings as Markdown for the summary of a job: a table of the problems and the logs of the
+// containers that failed. If nothing was found it lists the newest warning events of the objects instead.
+func Markdown(w io.Writer, release string, findings []Finding, objs Objects) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Why the rollout of `%s` failed\n\n", release)
+	if len(findings) == 0 {
+		writeWarnings(&b, objs.Events)
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("|Problem|Object|Details|\n|-|-|-|\n")
+	for _, f := range findings {
+		fmt.Fprintf(&b, "|%s|`%s`|%s|\n", f.Problem, f.Object(), cell(f.Message))
+	}
+	for _, f := range findings {
+		if len(f.Logs) == 0 {
+			continue
+		}
+		log := strings.Join(f.Logs, "\n")
+		fence := "```"
+		for strings.Contains(log, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "\n<details><summary>Log of %s</summary>\n\n%s\n%s\n%s\n\n</details>\n", f.Object(), fence, log, fence)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeWarnings writes the newest warning events as a table.
+func writeWarnings(b *strings.Builder, events []coreV1.Event) {
+	var warnings []coreV1.Event
+	for _, event := range events {
+		if event.Type == coreV1.EventTypeWarning {
+			warnings = append(warnings, event)
+		}
+	}
+	if len(warnings) == 0 {
+		b.WriteString("No problem was found and there are no warning events, see the log of helm upgrade.\n")
+		return
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[j].LastTimestamp.Before(&warnings[i].LastTimestamp)
+	})
+	if len(warnings) > maxWarnings {
+		warnings = warnings[:maxWarnings]
+	}
+	b.WriteString("No known problem was found, these are the newest warning events.\n\n|Reason|Object|Message|\n|-|-|-|\n")
+	for _, event := range warnings {
+		fmt.Fprintf(b, "|%s|`%s %s`|%s|\n", event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name, cell(event.Message))
+	}
+}
+
+// cell escapes the text for a cell of a Markdown table.
+func cell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,69 @@
+package rollout
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMarkdown(t *testing.T) {
+	warning := func(reason, name, message string, minute int) coreV1.Event {
+		return coreV1.Event{
+			Type:           coreV1.EventTypeWarning,
+			Reason:         reason,
+			Message:        message,
+			InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: name},
+			LastTimestamp:  metaV1.NewTime(time.Date(2026, 3, 1, 10, minute, 0, 0, time.UTC)),
+		}
+	}
+
+	tcs := []struct {
+		name     string
+		findings []Finding
+		objs     Objects
+		expected string
+	}{
+		{
+			name: "findings",
+			findings: []Finding{
+				{Problem: CrashLoop, Kind: "Pod", Name: "app-1", Container: "web", Message: "restarted 5 times", Logs: []string{"```", "panic: no | here"}},
+				{Problem: Unschedulable, Kind: "Pod", Name: "app-2", Message: "0/3 nodes are available:\n3 Insufficient memory | cpu."},
+			},
+			expected: "### Why the rollout of `app` failed\n\n" +
+				"|Problem|Object|Details|\n|-|-|-|\n" +
+				"|Crash loop|`Pod app-1/web`|restarted 5 times|\n" +
+				"|Unschedulable|`Pod app-2`|0/3 nodes are available: 3 Insufficient memory \\| cpu.|\n" +
+				"\n<details><summary>Log of Pod app-1/web</summary>\n\n````\n```\npanic: no | here\n````\n\n</details>\n",
+		},
+		{
+			name: "only warnings",
+			objs: Objects{Events: []coreV1.Event{
+				warning("Evicted", "app-1", "The node was low on resource: memory.", 1),
+				{Type: coreV1.EventTypeNormal, Reason: "Pulled", InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: "app-1"}},
+				warning("NodeNotReady", "app-2", "Node is not ready", 2),
+			}},
+			expected: "### Why the rollout of `app` failed\n\n" +
+				"No known problem was found, these are the newest warning events.\n\n" +
+				"|Reason|Object|Message|\n|-|-|-|\n" +
+				"|NodeNotReady|`Pod app-2`|Node is not ready|\n" +
+				"|Evicted|`Pod app-1`|The node was low on resource: memory.|\n",
+		},
+		{
+			name: "nothing",
+			expected: "### Why the rollout of `app` failed\n\n" +
+				"No problem was found and there are no warning events, see the log of helm upgrade.\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			require.NoError(t, Markdown(&b, "app", tc.findings, tc.objs))
+			require.Equal(t, tc.expected, b.String())
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package rollout explains why the rollout of a release failed, from the pods, jobs and events kubectl prints
+// after helm upgrade --atomic --wait gave up: containers in a crash loop, images that can't be pulled, failing
+// probes, a failed db-migrate hook and pods that can't be scheduled.
+//
+// helm rolls the release back before the diagnosis runs, which deletes the pods of the failed revision. Their
+// events are kept for an hour, so most problems are found in the events, and in the pods while they exist.
+package rollout
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// Objects are the objects of a namespace the diagnosis looks at.
+type Objects struct {
+	Pods   []coreV1.Pod
+	Jobs   []batchV1.Job
+	Events []coreV1.Event
+}
+
+// ParseObjects parses the output of kubectl get pods,jobs,events -o json, one or more Lists. Other kinds are
+// ignored.
+func ParseObjects(r io.Reader) (Objects, error) {
+	var objs Objects
+	dec := json.NewDecoder(r)
+	for {
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		err := dec.Decode(&list)
+		if errors.Is(err, io.EOF) {
+			return objs, nil
+		}
+		if err != nil {
+			return Objects{}, fmt.Errorf("parse objects: %w", err)
+		}
+		for _, item := range list.Items {
+			var meta struct {
+				Kind string `json:"kind"`
+			}
+			if err := json.Unmarshal(item, &meta); err != nil {
+				return Objects{}, fmt.Errorf("parse objects: %w", err)
+			}
+			switch meta.Kind {
+			case "Pod":
+				var pod coreV1.Pod
+				err = json.Unmarshal(item, &pod)
+				objs.Pods = append(objs.Pods, pod)
+			case "Job":
+				var job batchV1.Job
+				err = json.Unmarshal(item, &job)
+				objs.Jobs = append(objs.Jobs, job)
+			case "Event":
+				var event coreV1.Event
+				err = json.Unmarshal(item, &event)
+				objs.Events = append(objs.Events, event)
+			}
+			if err != nil {
+				return Objects{}, fmt.Errorf("parse %s: %w", meta.Kind, err)
+			}
+		}
+	}
+}
+
+// Select returns the pods and jobs of the release that have all the labels, and the events about them. The
+// events about objects that no longer exist are selected if the name of the object starts with the release
+// name, which all objects of the chart do.
+func (o Objects) Select(release string, labels map[string]string) Objects {
+	var selected Objects
+	uids := map[string]bool{}
+	matches := func(objectLabels map[string]string) bool {
+		if objectLabels["release"] != release {
+			return false
+		}
+		for k, v := range labels {
+			if objectLabels[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+	for _, pod := range o.Pods {
+		if matches(pod.Labels) {
+			selected.Pods = append(selected.Pods, pod)
+			uids[string(pod.UID)] = true
+		}
+	}
+	for _, job := range o.Jobs {
+		if matches(job.Labels) {
+			selected.Jobs = append(selected.Jobs, job)
+			uids[string(job.UID)] = true
+		}
+	}
+	for _, event := range o.Events {
+		involved := event.InvolvedObject
+		if uids[string(involved.UID)] || involved.Name == release || strings.HasPrefix(involved.Name, release+"-") {
+			selected.Events = append(selected.Events, event)
+		}
+	}
+	return selected
+}
+
+// ParseSelector parses a label selector of equalities, like track=stable,tier=web.
+func ParseSelector(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+	if selector == "" {
+		return labels, nil
+	}
+	for _, requirement := range strings.Split(selector, ",") {
+		k, v, ok := strings.Cut(requirement, "=")
+		if !ok || k == "" || strings.ContainsAny(k, "!") {
+			return nil, fmt.Errorf("%q is not a label=value requirement", requirement)
+		}
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
+
+// logLines is how many lines of the log of a container are kept.
+const logLines = 20
+
+// Logs are the last lines of the logs of the containers, by pod and container name.
+type Logs map[string][]string
+
+func logKey(pod, container string) string {
+	return pod + "/" + container
+}
+
+// Get returns the last lines of the log of the container.
+func (l Logs) Get(pod, container string) []string {
+	return l[logKey(pod, container)]
+}
+
+// ParseLogs parses the output of kubectl logs --prefix, where every line starts with [pod/name/container].
+// Lines without the prefix are ignored.
+func ParseLogs(r io.Reader) (Logs, error) {
+	logs := Logs{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "[pod/") {
+			continue
+		}
+		rest := strings.TrimPrefix(line, "[pod/")
+		prefix, text, ok := strings.Cut(rest, "] ")
+		if !ok {
+			// an empty line of the log
+			if !strings.HasSuffix(rest, "]") {
+				continue
+			}
+			prefix = strings.TrimSuffix(rest, "]")
+		}
+		pod, container, ok := strings.Cut(prefix, "/")
+		if !ok {
+			continue
+		}
+		key := logKey(pod, container)
+		logs[key] = append(logs[key], text)
+		if len(logs[key]) > logLines {
+			logs[key] = logs[key][1:]
+		}
+	}
+	return logs, scanner.Err()
+}
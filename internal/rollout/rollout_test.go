@@ -0,0 +1,86 @@
+package rollout
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const release = "review-feature-x"
+
+func mustReadFixtures(t *testing.T) (Objects, Logs) {
+	t.Helper()
+	f, err := os.Open("testdata/failed-rollout.json")
+	require.NoError(t, err)
+	defer f.Close()
+	objs, err := ParseObjects(f)
+	require.NoError(t, err)
+
+	logFile, err := os.Open("testdata/failed-rollout.log")
+	require.NoError(t, err)
+	defer logFile.Close()
+	logs, err := ParseLogs(logFile)
+	require.NoError(t, err)
+	return objs, logs
+}
+
+func TestParseObjects(t *testing.T) {
+	objs, _ := mustReadFixtures(t)
+	require.Len(t, objs.Pods, 5)
+	require.Len(t, objs.Jobs, 1)
+	require.Len(t, objs.Events, 8)
+	require.Equal(t, "review-feature-x-db-migrate", objs.Jobs[0].Name)
+
+	_, err := ParseObjects(strings.NewReader(`{"items": [{"kind": "Pod", "spec": []}]}`))
+	require.Error(t, err)
+}
+
+func TestObjects_Select(t *testing.T) {
+	objs, _ := mustReadFixtures(t)
+
+	selected := objs.Select(release, map[string]string{})
+	require.Len(t, selected.Pods, 4)
+	require.Len(t, selected.Jobs, 1)
+	require.Len(t, selected.Events, 7, "the events of the deleted pod are selected by its name")
+
+	selected = objs.Select(release, map[string]string{"tier": "worker"})
+	require.Empty(t, selected.Pods)
+	require.Empty(t, selected.Jobs)
+	require.Len(t, selected.Events, 7)
+}
+
+func TestParseSelector(t *testing.T) {
+	labels, err := ParseSelector("track=stable, tier=web")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"track": "stable", "tier": "web"}, labels)
+
+	labels, err = ParseSelector("")
+	require.NoError(t, err)
+	require.Empty(t, labels)
+
+	_, err = ParseSelector("track!=canary")
+	require.EqualError(t, err, `"track!=canary" is not a label=value requirement`)
+}
+
+func TestParseLogs(t *testing.T) {
+	_, logs := mustReadFixtures(t)
+	require.Equal(t, []string{
+		"Starting server on port 5000",
+		"Error: connect ECONNREFUSED 10.43.0.5:5432",
+	}, logs.Get("review-feature-x-6b7d9c-abcde", "auto-deploy-app"))
+	require.Equal(t, []string{
+		"Operations to perform:",
+		"",
+		`django.db.utils.OperationalError: FATAL:  password authentication failed for user "app"`,
+	}, logs.Get("review-feature-x-db-migrate-pqrst", "auto-deploy-app"))
+
+	var b strings.Builder
+	for i := 0; i < 30; i++ {
+		b.WriteString("[pod/app/web] line\n")
+	}
+	logs, err := ParseLogs(strings.NewReader(b.String()))
+	require.NoError(t, err)
+	require.Len(t, logs.Get("app", "web"), logLines)
+}
@@ -0,0 +1,372 @@
+package waitfor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/dburl"
+)
+
+const (
+	// protocolVersion is version 3.0 of the PostgreSQL protocol
+	protocolVersion = 3 << 16
+	// sslRequestCode asks the server for TLS
+	sslRequestCode = 1234<<16 | 5679
+	// maxMessageLength is the longest message the check reads
+	maxMessageLength = 1 << 20
+	// scramMechanism is the only SASL mechanism the check supports
+	scramMechanism = "SCRAM-SHA-256"
+)
+
+// authentication requests of the server
+const (
+	authOK                = 0
+	authCleartextPassword = 3
+	authMD5Password       = 5
+	authSASL              = 10
+	authSASLContinue      = 11
+	authSASLFinal         = 12
+)
+
+// Postgres is a PostgreSQL server that accepts the user and the database.
+type Postgres struct {
+	Params dburl.Params
+}
+
+func (p Postgres) String() string {
+	s := "postgres://" + p.address() + "/" + p.Params.Database
+	if p.Params.User != "" {
+		s += " as " + p.Params.User
+	}
+	return s
+}
+
+// address returns the host:port of the server.
+func (p Postgres) address() string {
+	port := p.Params.Port
+	if port == 0 {
+		port = dburl.DefaultPort
+	}
+	return net.JoinHostPort(p.Params.Host, strconv.Itoa(port))
+}
+
+// Check connects to the server and logs in, the way a client does before it sends a query. The server isn't
+// ready while it is starting up or shutting down. A wrong user or password and unsupported authentication
+// methods are permanent errors.
+func (p Postgres) Check(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.address())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	rw, err := p.startTLS(conn)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(rw)
+
+	var startup bytes.Buffer
+	for _, param := range []string{"user", p.Params.User, "database", p.Params.Database, "application_name", "wait-for"} {
+		startup.WriteString(param)
+		startup.WriteByte(0)
+	}
+	startup.WriteByte(0)
+	if err := writeStartup(rw, protocolVersion, startup.Bytes()); err != nil {
+		return err
+	}
+
+	var scram *scramClient
+	for {
+		typ, body, err := readMessage(r)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'R':
+			if len(body) < 4 {
+				return errors.New("the server sent an invalid authentication request")
+			}
+			code, data := binary.BigEndian.Uint32(body), body[4:]
+			switch code {
+			case authOK:
+			case authCleartextPassword:
+				err = writeMessage(rw, 'p', append([]byte(p.Params.Password), 0))
+			case authMD5Password:
+				if len(data) != 4 {
+					return errors.New("the server sent an invalid MD5 salt")
+				}
+				err = writeMessage(rw, 'p', append([]byte(md5Password(p.Params.User, p.Params.Password, data)), 0))
+			case authSASL:
+				if scram, err = newScramClient(p.Params.Password, data); err != nil {
+					return permanent(err)
+				}
+				err = writeMessage(rw, 'p', scram.firstMessage())
+			case authSASLContinue:
+				if scram == nil {
+					return errors.New("the server continued a SASL exchange that didn't start")
+				}
+				var message []byte
+				if message, err = scram.finalMessage(data); err != nil {
+					return permanent(err)
+				}
+				err = writeMessage(rw, 'p', message)
+			case authSASLFinal:
+				if scram == nil {
+					return errors.New("the server finished a SASL exchange that didn't start")
+				}
+				if err := scram.verify(data); err != nil {
+					return permanent(err)
+				}
+			default:
+				return permanent(fmt.Errorf("the server asks for authentication method %d, which is not supported", code))
+			}
+			if err != nil {
+				return err
+			}
+		case 'E':
+			return serverError(body)
+		case 'Z':
+			// ready for query: the server accepted the login, say goodbye
+			_ = writeMessage(rw, 'X', nil)
+			return nil
+		default:
+			// parameter status, backend key data, notices
+		}
+	}
+}
+
+// startTLS asks the server for TLS depending on the sslmode and returns the connection to use.
+func (p Postgres) startTLS(conn net.Conn) (io.ReadWriter, error) {
+	mode := p.Params.SSLMode
+	if mode == "disable" {
+		return conn, nil
+	}
+	if err := writeStartup(conn, sslRequestCode, nil); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	switch answer[0] {
+	case 'N':
+		if mode == "require" || mode == "verify-ca" || mode == "verify-full" {
+			return nil, permanent(fmt.Errorf("the server doesn't support SSL, which sslmode %s requires", mode))
+		}
+		return conn, nil
+	case 'S':
+	default:
+		return nil, fmt.Errorf("the server answered the SSL request with %q", answer[0])
+	}
+
+	config := &tls.Config{ServerName: p.Params.Host}
+	switch mode {
+	case "verify-full":
+	case "verify-ca":
+		// the certificate must be signed by a trusted CA, but may be for another host
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			opts := x509.VerifyOptions{Intermediates: x509.NewCertPool()}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	default:
+		// like libpq, the certificate isn't verified without verify-ca or verify-full
+		config.InsecureSkipVerify = true
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// serverError returns the error in an error response. Authentication errors are permanent.
+func serverError(body []byte) error {
+	fields := make(map[byte]string)
+	for len(body) > 1 {
+		end := bytes.IndexByte(body[1:], 0)
+		if end < 0 {
+			break
+		}
+		fields[body[0]] = string(body[1 : end+1])
+		body = body[end+2:]
+	}
+	err := fmt.Errorf("%s: %s (SQLSTATE %s)", fields['S'], fields['M'], fields['C'])
+	// invalid_authorization_specification and invalid_password
+	if code := fields['C']; code == "28000" || code == "28P01" {
+		return permanent(err)
+	}
+	return err
+}
+
+// md5Password returns the answer to an MD5 authentication request.
+func md5Password(user string, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + user))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// writeStartup writes a startup or SSL request message, which have no type.
+func writeStartup(w io.Writer, code uint32, body []byte) error {
+	message := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(message, uint32(8+len(body)))
+	binary.BigEndian.PutUint32(message[4:], code)
+	_, err := w.Write(append(message, body...))
+	return err
+}
+
+// writeMessage writes a message of the type.
+func writeMessage(w io.Writer, typ byte, body []byte) error {
+	message := make([]byte, 5, 5+len(body))
+	message[0] = typ
+	binary.BigEndian.PutUint32(message[1:], uint32(4+len(body)))
+	_, err := w.Write(append(message, body...))
+	return err
+}
+
+// readMessage reads a message and returns its type and body.
+func readMessage(r *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > maxMessageLength {
+		return 0, nil, fmt.Errorf("the server sent a message of invalid length %d", length)
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+// scramClient is the client side of a SCRAM-SHA-256 exchange (RFC 5802, RFC 7677). The password is used as it
+// is, without SASLprep, which only makes a difference for passwords with unusual Unicode characters.
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+// newScramClient starts a SCRAM-SHA-256 exchange if the server offers it among its mechanisms.
+func newScramClient(password string, mechanisms []byte) (*scramClient, error) {
+	offered := strings.Split(strings.TrimRight(string(mechanisms), "\x00"), "\x00")
+	supported := false
+	for _, mechanism := range offered {
+		supported = supported || mechanism == scramMechanism
+	}
+	if !supported {
+		return nil, fmt.Errorf("the server offers the SASL mechanisms %s, only %s is supported", strings.Join(offered, ", "), scramMechanism)
+	}
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	clientNonce := base64.StdEncoding.EncodeToString(nonce)
+	// the user name is taken from the startup message, so it is empty here
+	return &scramClient{password: password, clientNonce: clientNonce, clientFirstBare: "n=,r=" + clientNonce}, nil
+}
+
+// firstMessage returns the SASLInitialResponse.
+func (c *scramClient) firstMessage() []byte {
+	first := "n,," + c.clientFirstBare
+	var b bytes.Buffer
+	b.WriteString(scramMechanism)
+	b.WriteByte(0)
+	_ = binary.Write(&b, binary.BigEndian, int32(len(first)))
+	b.WriteString(first)
+	return b.Bytes()
+}
+
+// finalMessage returns the SASLResponse with the proof of the password for the server-first-message.
+func (c *scramClient) finalMessage(serverFirst []byte) ([]byte, error) {
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(string(serverFirst), ",") {
+		if name, value, ok := strings.Cut(attribute, "="); ok {
+			attributes[name] = value
+		}
+	}
+	nonce, salt64, iterations64 := attributes["r"], attributes["s"], attributes["i"]
+	if !strings.HasPrefix(nonce, c.clientNonce) || len(nonce) == len(c.clientNonce) {
+		return nil, errors.New("the server sent an invalid SCRAM nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return nil, errors.New("the server sent an invalid SCRAM salt")
+	}
+	iterations, err := strconv.Atoi(iterations64)
+	if err != nil || iterations < 1 {
+		return nil, errors.New("the server sent an invalid SCRAM iteration count")
+	}
+
+	saltedPassword := scramHi([]byte(c.password), salt, iterations)
+	clientKey := hmacSHA256(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientFinalWithoutProof := "c=biws,r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + string(serverFirst) + "," + clientFinalWithoutProof)
+	clientSignature := hmacSHA256(storedKey[:], authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	c.serverSignature = hmacSHA256(hmacSHA256(saltedPassword, []byte("Server Key")), authMessage)
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verify checks the signature of the server in the server-final-message.
+func (c *scramClient) verify(serverFinal []byte) error {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(string(serverFinal), "v="))
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return errors.New("the server sent an invalid SCRAM signature")
+	}
+	return nil
+}
+
+// scramHi is the Hi function of SCRAM, PBKDF2 with HMAC-SHA-256 for a single block.
+func scramHi(password []byte, salt []byte, iterations int) []byte {
+	u := hmacSHA256(password, append(append([]byte{}, salt...), 0, 0, 0, 1))
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		u = hmacSHA256(password, u)
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// hmacSHA256 returns the HMAC-SHA-256 of data.
+func hmacSHA256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,345 @@
+package waitfor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SSHOC/gl-autodevops-minimal-port/internal/dburl"
+)
+
+// fakePostgres is a PostgreSQL server that speaks just enough of the protocol for the handshake.
+type fakePostgres struct {
+	auth     string // trust, cleartext, md5, scram or an unsupported method
+	user     string
+	password string
+	// startingUp is the number of connections that are refused because the server is starting up.
+	startingUp int
+	// ssl is the answer to an SSL request, N if empty
+	ssl byte
+}
+
+// serve accepts connections until the listener is closed.
+func (s *fakePostgres) serve(t *testing.T) dburl.Params {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			refuse := s.startingUp > 0
+			if refuse {
+				s.startingUp--
+			}
+			go func() {
+				defer conn.Close()
+				_ = s.handle(conn, refuse)
+			}()
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return dburl.Params{Host: "127.0.0.1", Port: addr.Port, Database: "test", User: s.user, Password: s.password}
+}
+
+// handle runs the handshake of a connection.
+func (s *fakePostgres) handle(conn net.Conn, startingUp bool) error {
+	r := bufio.NewReader(conn)
+	code, params, err := readStartup(r)
+	if err != nil {
+		return err
+	}
+	if code == sslRequestCode {
+		answer := s.ssl
+		if answer == 0 {
+			answer = 'N'
+		}
+		if _, err := conn.Write([]byte{answer}); err != nil || answer != 'N' {
+			return err
+		}
+		if code, params, err = readStartup(r); err != nil {
+			return err
+		}
+	}
+	if code != protocolVersion {
+		return errors.New("unexpected protocol version")
+	}
+	if startingUp {
+		return writeError(conn, "57P03", "the database system is starting up")
+	}
+	if params["user"] != s.user || params["database"] != "test" {
+		return writeError(conn, "28000", `role "`+params["user"]+`" does not exist`)
+	}
+
+	ok := true
+	switch s.auth {
+	case "trust":
+	case "cleartext":
+		if err := writeAuth(conn, authCleartextPassword, nil); err != nil {
+			return err
+		}
+		password, err := readPassword(r)
+		if err != nil {
+			return err
+		}
+		ok = string(bytes.TrimRight(password, "\x00")) == s.password
+	case "md5":
+		salt := []byte{1, 2, 3, 4}
+		if err := writeAuth(conn, authMD5Password, salt); err != nil {
+			return err
+		}
+		password, err := readPassword(r)
+		if err != nil {
+			return err
+		}
+		ok = string(bytes.TrimRight(password, "\x00")) == md5Password(s.user, s.password, salt)
+	case "scram":
+		if ok, err = s.scram(conn, r); err != nil {
+			return err
+		}
+	default:
+		return writeAuth(conn, 7, nil) // GSSAPI
+	}
+	if !ok {
+		return writeError(conn, "28P01", `password authentication failed for user "`+s.user+`"`)
+	}
+
+	if err := writeAuth(conn, authOK, nil); err != nil {
+		return err
+	}
+	if err := writeMessage(conn, 'S', []byte("server_version\x0015.9\x00")); err != nil {
+		return err
+	}
+	if err := writeMessage(conn, 'Z', []byte{'I'}); err != nil {
+		return err
+	}
+	typ, _, err := readMessage(r)
+	if err == nil && typ != 'X' {
+		return errors.New("expected a terminate message")
+	}
+	return err
+}
+
+// scram runs the server side of a SCRAM-SHA-256 exchange and returns whether the proof was right.
+func (s *fakePostgres) scram(conn net.Conn, r *bufio.Reader) (bool, error) {
+	if err := writeAuth(conn, authSASL, []byte("SCRAM-SHA-256-PLUS\x00SCRAM-SHA-256\x00\x00")); err != nil {
+		return false, err
+	}
+	initial, err := readPassword(r)
+	if err != nil {
+		return false, err
+	}
+	mechanism, rest, _ := bytes.Cut(initial, []byte{0})
+	if string(mechanism) != scramMechanism || len(rest) < 4 {
+		return false, errors.New("invalid SASLInitialResponse")
+	}
+	clientFirstBare := strings.TrimPrefix(string(rest[4:]), "n,,")
+	clientNonce := strings.TrimPrefix(clientFirstBare, "n=,r=")
+
+	salt := []byte("salt of the fake")
+	serverFirst := "r=" + clientNonce + "server-nonce,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+	if err := writeAuth(conn, authSASLContinue, []byte(serverFirst)); err != nil {
+		return false, err
+	}
+	final, err := readPassword(r)
+	if err != nil {
+		return false, err
+	}
+	withoutProof, proof64, _ := strings.Cut(string(final), ",p=")
+	proof, err := base64.StdEncoding.DecodeString(proof64)
+	if err != nil {
+		return false, err
+	}
+
+	saltedPassword := scramHi([]byte(s.password), salt, 4096)
+	storedKey := sha256.Sum256(hmacSHA256(saltedPassword, []byte("Client Key")))
+	authMessage := []byte(clientFirstBare + "," + serverFirst + "," + withoutProof)
+	clientSignature := hmacSHA256(storedKey[:], authMessage)
+	clientKey := make([]byte, len(proof))
+	for i := range proof {
+		clientKey[i] = proof[i] ^ clientSignature[i%len(clientSignature)]
+	}
+	if sum := sha256.Sum256(clientKey); !hmac.Equal(sum[:], storedKey[:]) {
+		return false, nil
+	}
+	serverSignature := hmacSHA256(hmacSHA256(saltedPassword, []byte("Server Key")), authMessage)
+	return true, writeAuth(conn, authSASLFinal, []byte("v="+base64.StdEncoding.EncodeToString(serverSignature)))
+}
+
+func readStartup(r *bufio.Reader) (uint32, map[string]string, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header)-8)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	params := make(map[string]string)
+	fields := strings.Split(strings.TrimRight(string(body), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		params[fields[i]] = fields[i+1]
+	}
+	return binary.BigEndian.Uint32(header[4:]), params, nil
+}
+
+func readPassword(r *bufio.Reader) ([]byte, error) {
+	typ, body, err := readMessage(r)
+	if err == nil && typ != 'p' {
+		err = errors.New("expected a password message")
+	}
+	return body, err
+}
+
+func writeAuth(w io.Writer, code uint32, data []byte) error {
+	body := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(body, code)
+	return writeMessage(w, 'R', append(body, data...))
+}
+
+func writeError(w io.Writer, code string, message string) error {
+	return writeMessage(w, 'E', []byte("SFATAL\x00C"+code+"\x00M"+message+"\x00\x00"))
+}
+
+func TestPostgres_Check(t *testing.T) {
+	tcs := []struct {
+		name     string
+		server   fakePostgres
+		password string // the password of the server if empty
+		sslMode  string
+
+		expectedError string
+		permanent     bool
+	}{
+		{
+			name:   "trust",
+			server: fakePostgres{auth: "trust", user: "user"},
+		},
+		{
+			name:   "cleartext",
+			server: fakePostgres{auth: "cleartext", user: "user", password: "p@ss word"},
+		},
+		{
+			name:   "md5",
+			server: fakePostgres{auth: "md5", user: "user", password: "secret"},
+		},
+		{
+			name:   "scram",
+			server: fakePostgres{auth: "scram", user: "user", password: "secret"},
+		},
+		{
+			name:    "sslmode disable",
+			server:  fakePostgres{auth: "scram", user: "user", password: "secret", ssl: 'E'},
+			sslMode: "disable",
+		},
+		{
+			name:          "wrong scram password",
+			server:        fakePostgres{auth: "scram", user: "user", password: "secret"},
+			password:      "wrong",
+			expectedError: `FATAL: password authentication failed for user "user" (SQLSTATE 28P01)`,
+			permanent:     true,
+		},
+		{
+			name:          "wrong md5 password",
+			server:        fakePostgres{auth: "md5", user: "user", password: "secret"},
+			password:      "wrong",
+			expectedError: `FATAL: password authentication failed for user "user" (SQLSTATE 28P01)`,
+			permanent:     true,
+		},
+		{
+			name:          "starting up",
+			server:        fakePostgres{auth: "trust", user: "user", startingUp: 1},
+			expectedError: "FATAL: the database system is starting up (SQLSTATE 57P03)",
+		},
+		{
+			name:          "unsupported method",
+			server:        fakePostgres{auth: "gss", user: "user"},
+			expectedError: "the server asks for authentication method 7, which is not supported",
+			permanent:     true,
+		},
+		{
+			name:          "SSL required",
+			server:        fakePostgres{auth: "trust", user: "user"},
+			sslMode:       "require",
+			expectedError: "the server doesn't support SSL, which sslmode require requires",
+			permanent:     true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			server := tc.server
+			params := server.serve(t)
+			if tc.password != "" {
+				params.Password = tc.password
+			}
+			params.SSLMode = tc.sslMode
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := Postgres{Params: params}.Check(ctx)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				require.Equal(t, tc.permanent, IsPermanent(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPostgres_String(t *testing.T) {
+	p := Postgres{Params: dburl.Params{Host: "db", Database: "app", User: "user", Password: "secret"}}
+	require.Equal(t, "postgres://db:5432/app as user", p.String())
+}
+
+func TestWait_PostgresStartingUp(t *testing.T) {
+	server := fakePostgres{auth: "md5", user: "user", password: "secret", startingUp: 2}
+	params := server.serve(t)
+
+	var logs []string
+	err := Wait(context.Background(), []Dependency{Postgres{Params: params}}, Options{
+		Timeout:  5 * time.Second,
+		Interval: time.Millisecond,
+		Logf: func(format string, args ...interface{}) {
+			logs = append(logs, format)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"%s is not ready (attempt %d): %v",
+		"%s is not ready (attempt %d): %v",
+		"%s is ready",
+	}, logs)
+	require.Equal(t, "127.0.0.1:"+strconv.Itoa(params.Port), Postgres{Params: params}.address())
+}
+
+// TestScramClient checks the exchange against the example of RFC 7677.
+func TestScramClient(t *testing.T) {
+	c := &scramClient{password: "pencil", clientNonce: "rOprNGfwEbeRWgbNEkqO", clientFirstBare: "n=user,r=rOprNGfwEbeRWgbNEkqO"}
+	final, err := c.finalMessage([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	require.NoError(t, err)
+	require.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", string(final))
+	require.NoError(t, c.verify([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")))
+	require.EqualError(t, c.verify([]byte("v=AAAA")), "the server sent an invalid SCRAM signature")
+
+	_, err = c.finalMessage([]byte("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	require.EqualError(t, err, "the server sent an invalid SCRAM nonce")
+	_, err = newScramClient("pencil", []byte("SCRAM-SHA-256-PLUS\x00\x00"))
+	require.EqualError(t, err, "the server offers the SASL mechanisms SCRAM-SHA-256-PLUS, only SCRAM-SHA-256 is supported")
+}